@@ -0,0 +1,17 @@
+// Command filemac is an interactive shell for tagging, organizing and
+// searching document folders. It also runs commands non-interactively:
+//
+//	filemac s steuer 2024              one command
+//	filemac -c 'cd ~/docs; i; a 3 x'   a command line
+//	filemac run retag.fm               a script
+package main
+
+import (
+	"os"
+
+	"github.com/tenzokai/filemac/pkg/shell"
+)
+
+func main() {
+	os.Exit(shell.New().Main(os.Args[1:]))
+}
@@ -0,0 +1,23 @@
+// Package testutil holds helpers shared by the package tests.
+package testutil
+
+import (
+	"os"
+	"testing"
+)
+
+// WithStdin runs f with os.Stdin reading input. Commands that prompt
+// through liner or bufio on os.Stdin can be driven this way.
+func WithStdin(t testing.TB, input string, f func()) {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.WriteString(input)
+	w.Close()
+	old := os.Stdin
+	os.Stdin = r
+	defer func() { os.Stdin = old; r.Close() }()
+	f()
+}
@@ -0,0 +1,171 @@
+// Package autotag adds tags to catalog entries automatically, from
+// .catrules and from the tag classifier.
+package autotag
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/tenzokai/filemac/pkg/catalog"
+	"github.com/tenzokai/filemac/pkg/classify"
+	"github.com/tenzokai/filemac/pkg/rules"
+)
+
+// Change lists the tags added to one entry.
+type Change struct {
+	Dir   string
+	Name  string
+	Added []string
+}
+
+// CmdAutotag applies the .catrules of each catalog visible from the current
+// folder to its existing entries: autotag [--dry-run] [--min-confidence p].
+// With --min-confidence untagged entries also get every tag the classifier
+// predicts with at least confidence p (0..1).
+func CmdAutotag(w io.Writer, args []string) error {
+	dryRun := false
+	minConf := -1.0
+	for i := 0; i < len(args); i++ {
+		switch a := args[i]; {
+		case a == "-n" || a == "--dry-run":
+			dryRun = true
+		case a == "--min-confidence" && i+1 < len(args):
+			i++
+			p, err := strconv.ParseFloat(args[i], 64)
+			if err != nil || p <= 0 || p > 1 {
+				return fmt.Errorf("--min-confidence needs a number between 0 and 1")
+			}
+			minConf = p
+		default:
+			return fmt.Errorf("usage: autotag [--dry-run] [--min-confidence p]")
+		}
+	}
+	cwd, _ := os.Getwd()
+	var changes []Change
+	if minConf > 0 {
+		// predict before the rules run, so "untagged" means untagged by hand
+		m, err := classify.ForDir(cwd)
+		if err != nil {
+			return fmt.Errorf("autotag: %w", err)
+		}
+		changes, err = ApplyModel(cwd, m, minConf, dryRun)
+		if err != nil {
+			return fmt.Errorf("autotag: %w", err)
+		}
+	}
+	ruled, err := ApplyRules(cwd, dryRun)
+	if err != nil {
+		return fmt.Errorf("autotag: %w", err)
+	}
+	changes = append(changes, ruled...)
+	for _, c := range changes {
+		fmt.Fprintf(w, "%s: +%s\n", c.Name, strings.Join(c.Added, ", +"))
+	}
+	switch {
+	case len(changes) == 0:
+		fmt.Fprintln(w, "no tags added")
+	case dryRun:
+		fmt.Fprintf(w, "(dry run, %d changes)\n", len(changes))
+	default:
+		fmt.Fprintf(w, "%d entries tagged\n", len(changes))
+	}
+	return nil
+}
+
+// ApplyModel gives untagged entries of the catalogs visible from dir the
+// tags m predicts with at least minConf. Nothing is saved when dryRun is set.
+func ApplyModel(dir string, m *classify.Model, minConf float64, dryRun bool) ([]Change, error) {
+	catalog.Lock()
+	defer catalog.Unlock()
+	cats, err := classify.LoadCatalogs(dir)
+	if err != nil {
+		return nil, err
+	}
+	var changes []Change
+	for _, c := range cats {
+		dirty := false
+		for i := range c.Entries {
+			e := &c.Entries[i]
+			if len(e.Tags) > 0 {
+				continue
+			}
+			var added []string
+			for _, p := range m.Predict(c.EntryFeatures(i)) {
+				if p.Confidence < minConf {
+					break
+				}
+				added = append(added, p.Tag)
+			}
+			if len(added) > 0 {
+				e.Tags = added
+				changes = append(changes, Change{Dir: c.Dir, Name: e.Name, Added: added})
+				dirty = true
+			}
+		}
+		if dirty && !dryRun {
+			if err := catalog.SaveCatalogAt(c.File, c.Entries); err != nil {
+				return changes, err
+			}
+		}
+	}
+	return changes, nil
+}
+
+// ApplyRules adds the tags of matching rules to the entries of every
+// catalog visible from dir, using the .catrules file of each catalog's own
+// folder. Default tags are left to init. Nothing is saved when dryRun is set.
+func ApplyRules(dir string, dryRun bool) ([]Change, error) {
+	catalog.Lock()
+	defer catalog.Unlock()
+	sources, err := catalog.ResolveSources(dir)
+	if err != nil {
+		return nil, err
+	}
+	var changes []Change
+	for _, src := range sources {
+		set, err := rules.Load(src.Dir)
+		if err != nil {
+			return changes, err
+		}
+		if len(set.Rules) == 0 {
+			continue
+		}
+		entries, err := catalog.LoadCatalogAt(src.File)
+		if err != nil {
+			return changes, err
+		}
+		dirty := false
+		for i := range entries {
+			e := &entries[i]
+			var added []string
+			for _, t := range set.Match(src.Dir, e.Name) {
+				if !hasTag(e.Tags, t) {
+					e.Tags = append(e.Tags, t)
+					added = append(added, t)
+				}
+			}
+			if len(added) > 0 {
+				changes = append(changes, Change{Dir: src.Dir, Name: e.Name, Added: added})
+				dirty = true
+			}
+		}
+		if dirty && !dryRun {
+			if err := catalog.SaveCatalogAt(src.File, entries); err != nil {
+				return changes, err
+			}
+		}
+	}
+	return changes, nil
+}
+
+func hasTag(tags []string, tag string) bool {
+	for _, t := range tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
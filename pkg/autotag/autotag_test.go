@@ -0,0 +1,40 @@
+package autotag
+
+import (
+    "os"
+    "path/filepath"
+    "strings"
+    "testing"
+
+    "github.com/tenzokai/filemac/pkg/catalog"
+)
+
+func TestApplyRules(t *testing.T) {
+    dir := t.TempDir()
+    catfile := filepath.Join(dir, ".cat")
+    catalog.SaveCatalogAt(catfile, []catalog.CatEntry{
+        {Name: "Allianz_2024.pdf", Type: "file", Tags: []string{"versicherung"}},
+        {Name: "Lohnsteuer.pdf", Type: "file"},
+        {Name: "urlaub.jpg", Type: "file"},
+    })
+    os.WriteFile(filepath.Join(dir, ".catrules"), []byte("*allianz* -> versicherung 2024\n*lohnsteuer* -> steuer arbeit\ndefault -> neu\n"), 0644)
+
+    changes, err := ApplyRules(dir, true)
+    if err != nil || len(changes) != 2 {
+        t.Fatalf("dry run: %+v, %v", changes, err)
+    }
+    if entries, _ := catalog.LoadCatalogAt(catfile); len(entries[1].Tags) != 0 {
+        t.Error("dry run saved the catalog")
+    }
+    if _, err := ApplyRules(dir, false); err != nil {
+        t.Fatal(err)
+    }
+    entries, _ := catalog.LoadCatalogAt(catfile)
+    var got []string
+    for _, e := range entries {
+        got = append(got, strings.Join(e.Tags, ","))
+    }
+    if want := "versicherung,2024|steuer,arbeit|"; strings.Join(got, "|") != want {
+        t.Errorf("tags = %q, want %q", strings.Join(got, "|"), want)
+    }
+}
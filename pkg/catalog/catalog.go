@@ -3,19 +3,44 @@ package catalog
 import (
 	"bufio"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
+
+	"github.com/tenzokai/filemac/pkg/config"
+	"github.com/tenzokai/filemac/pkg/opener"
+	"github.com/tenzokai/filemac/pkg/rules"
+	"github.com/tenzokai/filemac/pkg/table"
 )
 
 var CatalogFilename = ".cat"
 
 type CatEntry struct {
-	Raw  string
-	Name string
-	Type string // "file" or "url"
-	Tags []string
+	Raw   string
+	Name  string
+	Type  string // "file" or "url"
+	Tags  []string
+	Title string // optional display title, stored as "*@title=..."
+	Note  string // optional free-text note, stored as "*@note=..."
+}
+
+// Attribute fields share the tag section of a catalog line but start with
+// "@key=", so older versions simply show them as tags.
+const (
+	titleField = "@title="
+	noteField  = "@note="
+)
+
+// CheckTag returns ErrFieldTag if tag starts like an attribute field and
+// so would not survive a round trip through the .cat file.
+func CheckTag(tag string) error {
+	if strings.HasPrefix(tag, titleField) || strings.HasPrefix(tag, noteField) {
+		return fmt.Errorf("%w: '%s'", ErrFieldTag, tag)
+	}
+	return nil
 }
 
 // Load catalog file, create if not exists
@@ -23,7 +48,7 @@ func LoadCatalog() ([]CatEntry, error) {
 	// Only open if file exists
 	_, err := os.Stat(CatalogFilename)
 	if os.IsNotExist(err) {
-		return nil, fmt.Errorf(".cat not found in this directory")
+		return nil, fmt.Errorf("%w in this directory (run 'i' to create one)", ErrNoCatalog)
 	} else if err != nil {
 		return nil, err
 	}
@@ -66,6 +91,14 @@ func ParseCatalogLine(line string) CatEntry {
 	if tagsPart != "" {
 		for _, tag := range strings.Split(tagsPart, "*") {
 			tag = strings.TrimSpace(tag)
+			if strings.HasPrefix(tag, titleField) {
+				e.Title = strings.TrimPrefix(tag, titleField)
+				continue
+			}
+			if strings.HasPrefix(tag, noteField) {
+				e.Note = strings.TrimPrefix(tag, noteField)
+				continue
+			}
 			if tag != "" {
 				e.Tags = append(e.Tags, tag)
 			}
@@ -81,141 +114,171 @@ func ParseCatalogLine(line string) CatEntry {
 	return e
 }
 
-// Print the catalog as a table
-// Optionally print with preserved original indices (1-based positions from underlying catalog file).
-func printCatalogWithIndices(entries []CatEntry, indices []int) {
-       fmt.Printf("%-6s | %-6s | %-36s | %s\n", "num", "type", "name", "tags")
-       for i, e := range entries {
-               entryType := e.Type
-               if strings.Contains(e.Name, ":") {
-                       entryType = "url"
-               } else {
-                       entryType = "file"
-               }
-               num := i+1
-               if indices != nil && i < len(indices) {
-                       num = indices[i]+1
-               }
-               fmt.Printf("%-6d | %-6s | %-36s | %s\n", num, entryType, truncate(e.Name, 36), strings.Join(e.Tags, ", "))
-       }
-       if len(entries) == 0 {
-               fmt.Printf("(catalog is empty)\n")
-       }
-}
-
-// Backwards compat: printCatalog with no indices.
-func printCatalog(entries []CatEntry) {
-       printCatalogWithIndices(entries, nil)
+// printCatalogTable writes the table with the [view] columns to w; long
+// adds title, note and PDF details below each entry. indices holds the
+// catalog numbers of entries (0-based); nil numbers them in order.
+func printCatalogTable(w io.Writer, entries []CatEntry, indices []int, long bool) {
+	cwd, _ := os.Getwd()
+	cfg, err := config.Load()
+	if err != nil {
+		fmt.Fprintln(w, "config error:", err)
+	}
+	columns := map[string]table.Column{
+		"num":   {Title: "num", Min: 6},
+		"type":  {Title: "type", Min: 6},
+		"name":  {Title: "name", Min: 16, Middle: true},
+		"title": {Title: "title", Min: 10},
+		"date":  {Title: "date", Min: 10},
+		"tags":  {Title: "tags", Min: 10, Wrap: true},
+	}
+	t := table.New()
+	for _, c := range cfg.View.Columns {
+		t.Columns = append(t.Columns, columns[c])
+	}
+	for i, e := range entries {
+		entryType := "file"
+		if strings.Contains(e.Name, ":") {
+			entryType = "url"
+		}
+		num := i + 1
+		if indices != nil && i < len(indices) {
+			num = indices[i] + 1
+		}
+		var cells []string
+		for _, c := range cfg.View.Columns {
+			switch c {
+			case "num":
+				cells = append(cells, strconv.Itoa(num))
+			case "type":
+				cells = append(cells, entryType)
+			case "name":
+				cells = append(cells, e.Name)
+			case "title":
+				cells = append(cells, e.Title)
+			case "date":
+				date := ""
+				if d, _ := EntryDate(cwd, e); !d.IsZero() {
+					date = d.Format("2006-01-02")
+				}
+				cells = append(cells, date)
+			case "tags":
+				cells = append(cells, strings.Join(e.Tags, ", "))
+			}
+		}
+		t.Add(cells...)
+		if long && e.Title != "" {
+			t.Extra("title: " + e.Title)
+		}
+		if long && e.Note != "" {
+			t.Extra("note:  " + e.Note)
+		}
+		if long && e.Type == "file" && isPDF(e.Name) {
+			if sum := pdfSummary(EntryPath(cwd, e)); sum != "" {
+				t.Extra("pdf:   " + sum)
+			}
+		}
+	}
+	t.Render(w)
+	if len(entries) == 0 {
+		fmt.Fprintf(w, "(no entries)\n")
+	}
 }
 
-// Helper for truncating name display
-func truncate(s string, n int) string {
-	if len([]rune(s)) <= n {
-		return s
+// CmdViewCat shows the catalog: vc [-new] [-l] [-missing] [-tag <tag>]
+// [-ext <ext>] [-since <age|date>] [-sort name|date|ext|tags|mtime].
+// Entries keep their catalog numbers, so they still work with a, d and r.
+func CmdViewCat(w io.Writer, args ...string) error {
+	entries, err := LoadCatalog()
+	if err != nil {
+		return err
+	}
+	opts, err := ParseViewArgs(args)
+	if err != nil {
+		return fmt.Errorf("vc: %w", err)
 	}
-	runes := []rune(s)
-	return string(runes[:n-3]) + "..."
+	if opts.Sort == "" {
+		cfg, _ := config.Load()
+		opts.Sort = cfg.View.Sort
+	}
+	cwd, _ := os.Getwd()
+	indices := SelectEntries(cwd, entries, opts)
+	shown := make([]CatEntry, len(indices))
+	for i, idx := range indices {
+		shown[i] = entries[idx]
+	}
+	printCatalogTable(w, shown, indices, opts.Long)
+	if len(shown) < len(entries) {
+		fmt.Fprintf(w, "(%d of %d entries)\n", len(shown), len(entries))
+	}
+	return nil
 }
 
-// CmdViewCat optionally takes "-new". If used, only entries with no tags are shown.
-func CmdViewCat(args ...string) {
+// CmdNote sets the note of entry num: note <num> <text...>. Without text the
+// current note is shown, "-" clears it.
+func CmdNote(w io.Writer, num string, text []string) error {
+	Lock()
+	defer Unlock()
 	entries, err := LoadCatalog()
 	if err != nil {
-		fmt.Println("catalog error:", err)
-		return
-	}
-	fmt.Printf("args: %v args-lens: %v\n", args, len(args))
-       if len(args) > 0 && args[0] == "-new" {
-               var newEntries []CatEntry
-               var indices []int
-               for idx, e := range entries {
-                       if len(e.Tags) == 0 {
-                               newEntries = append(newEntries, e)
-                               indices = append(indices, idx)
-                       }
-               }
-               printCatalogWithIndices(newEntries, indices)
-       } else {
-               printCatalog(entries)
-       }
+		return err
+	}
+	i, err := EntryIndex(num, len(entries))
+	if err != nil {
+		return err
+	}
+	entry := &entries[i]
+	note := strings.TrimSpace(strings.Join(text, " "))
+	if note == "" {
+		if entry.Note == "" {
+			fmt.Fprintf(w, "entry %d has no note\n", i+1)
+		} else {
+			fmt.Fprintln(w, entry.Note)
+		}
+		return nil
+	}
+	if note == "-" {
+		entry.Note = ""
+	} else {
+		entry.Note = fieldValue(note)
+	}
+	if err := SaveCatalog(entries); err != nil {
+		return fmt.Errorf("error saving catalog: %w", err)
+	}
+	if entry.Note == "" {
+		fmt.Fprintf(w, "note removed from entry %d\n", i+1)
+	} else {
+		fmt.Fprintf(w, "note set on entry %d\n", i+1)
+	}
+	return nil
 }
 
-func CmdWalkthrough(num string) {
+// CmdOpen opens catalog entry num (as numbered in 'vc') with the configured opener.
+func CmdOpen(w io.Writer, num string) error {
 	entries, err := LoadCatalog()
 	if err != nil {
-		fmt.Println("catalog error:", err)
-		return
+		return err
 	}
-	if len(entries) == 0 {
-		fmt.Println("No entries in catalog.")
-		return
-	}
-	startIdx := 0
-	if num != "" {
-		n, err := strconv.Atoi(num)
-		if err == nil && n >= 1 && n <= len(entries) {
-			startIdx = n - 1
-		}
+	i, err := EntryIndex(num, len(entries))
+	if err != nil {
+		return err
 	}
-	reader := bufio.NewReader(os.Stdin)
-	lastChangedIdx := -1
-	skipped := 0
-	for i := startIdx; i < len(entries); i++ {
-		fmt.Printf("\nEntry #%d / %d:\n", i+1, len(entries))
-		fmt.Printf("  Name: %s\n  Tags: %s\n", entries[i].Name, strings.Join(entries[i].Tags, ", "))
-	walkthroughInput:
-		for {
-			fmt.Print("Enter tags (comma-separated) or blank to skip, 'stop' to abort: ")
-			line, _ := reader.ReadString('\n')
-			line = strings.TrimSpace(line)
-			if line == "stop" {
-				fmt.Printf("Aborted at entry #%d (%d left).\n", i+1, len(entries)-i)
-				if lastChangedIdx != -1 {
-					fmt.Printf("Last changed entry: #%d\n", lastChangedIdx+1)
-				}
-				return
-			}
-			if line == "" {
-				skipped++
-				break walkthroughInput
-			}
-			// parse, apply tags
-			var tags []string
-			for _, t := range strings.Split(line, ",") {
-				t = strings.TrimSpace(t)
-				if t != "" {
-					tags = append(tags, t)
-				}
-			}
-			fmt.Printf("New tags: %s\n", strings.Join(tags, ", "))
-			fmt.Print("Correct? y/n: ")
-			yn, _ := reader.ReadString('\n')
-			yn = strings.ToLower(strings.TrimSpace(yn))
-			if yn == "y" {
-				entries[i].Tags = tags
-				lastChangedIdx = i
-				// Save after each update
-				saveErr := SaveCatalog(entries)
-				if saveErr != nil {
-					fmt.Printf("Error saving: %v\n", saveErr)
-				} else {
-					fmt.Println("Saved.")
-				}
-				break walkthroughInput
-			} // else repeat entry
-		}
+	cwd, _ := os.Getwd()
+	target := EntryPath(cwd, entries[i])
+	fmt.Fprintf(w, "Opening %s...\n", target)
+	if err := opener.Open(target); err != nil {
+		return fmt.Errorf("error opening: %w", err)
 	}
-	fmt.Printf("Finished walkthrough. Changed %d, skipped %d entries.\n", lastChangedIdx+1, skipped)
+	return nil
 }
-func CmdLink(paths []string) {
+
+// CmdLink writes the given folders to .catlink, making this folder a hub.
+func CmdLink(w io.Writer, paths []string) error {
 	if len(paths) == 0 {
-		fmt.Println("No paths given for .catlink")
-		return
+		return fmt.Errorf("no paths given for .catlink")
 	}
 	f, err := os.Create(".catlink")
 	if err != nil {
-		fmt.Printf("error creating .catlink: %v\n", err)
-		return
+		return fmt.Errorf("error creating .catlink: %w", err)
 	}
 	defer f.Close()
 	for _, p := range paths {
@@ -229,98 +292,234 @@ func CmdLink(paths []string) {
 		}
 		fmt.Fprintln(f, abs)
 	}
-	fmt.Printf(".catlink created with %d paths\n", len(paths))
+	fmt.Fprintf(w, ".catlink created with %d paths\n", len(paths))
+	return nil
 }
 
 // SaveCatalog writes all entries back to .cat
 func SaveCatalog(entries []CatEntry) error {
-	f, err := os.Create(CatalogFilename)
+	return SaveCatalogAt(CatalogFilename, entries)
+}
+
+// SaveCatalogAt writes all entries to the given catalog file. The file is
+// replaced atomically, so readers never see a partly written catalog.
+func SaveCatalogAt(filename string, entries []CatEntry) error {
+	tmp, err := os.CreateTemp(filepath.Dir(filename), filepath.Base(filename)+".tmp*")
 	if err != nil {
 		return err
 	}
-	defer f.Close()
+	w := bufio.NewWriter(tmp)
 	for _, e := range entries {
-		line := e.Name
-		if len(e.Tags) > 0 {
-			line += "*" + strings.Join(e.Tags, "*")
-		}
-		if _, err := f.WriteString(line + "\n"); err != nil {
-			return err
-		}
+		w.WriteString(FormatCatalogLine(e) + "\n")
 	}
-	return nil
+	err = w.Flush()
+	if err == nil {
+		err = tmp.Chmod(0644)
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), filename)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+	return err
+}
+
+// FormatCatalogLine is the inverse of ParseCatalogLine.
+func FormatCatalogLine(e CatEntry) string {
+	line := e.Name
+	if len(e.Tags) > 0 {
+		line += "*" + strings.Join(e.Tags, "*")
+	}
+	if e.Title != "" {
+		line += "*" + titleField + fieldValue(e.Title)
+	}
+	if e.Note != "" {
+		line += "*" + noteField + fieldValue(e.Note)
+	}
+	return line
+}
+
+// fieldValue makes s safe to store in an attribute field.
+func fieldValue(s string) string {
+	s = strings.NewReplacer("*", " ", "\n", " ", "\r", " ").Replace(s)
+	return strings.TrimSpace(s)
 }
 
-// CmdViewLinkcat prints out contents of .catlink or a warning if missing.
-func CmdViewLinkcat() {
-	linkcat := ".catlink"
+// CmdViewLinkcat prints out contents of .catlink or a note if missing.
+func CmdViewLinkcat(w io.Writer) error {
 	cwd, err := os.Getwd()
 	if err != nil {
-		fmt.Println("Error: could not determine current directory")
-		return
+		return fmt.Errorf("could not determine current directory: %w", err)
 	}
-	f, err := os.Open(linkcat)
+	f, err := os.Open(".catlink")
 	if err != nil {
-		fmt.Printf("No .catlink in %s\n", cwd)
-		return
+		fmt.Fprintf(w, "No .catlink in %s\n", cwd)
+		return nil
 	}
 	defer f.Close()
 
 	scanner := bufio.NewScanner(f)
 	count := 0
 	for scanner.Scan() {
-		line := scanner.Text()
-		fmt.Println(line)
+		fmt.Fprintln(w, scanner.Text())
 		count++
 	}
 	if count == 0 {
-		fmt.Println("(Empty .catlink file)")
+		fmt.Fprintln(w, "(Empty .catlink file)")
 	}
+	return scanner.Err()
 }
 
-// CmdInitCatalog: synchronize .cat with directory files (add new, remove vanished)
-func CmdInitCatalog() {
-	cwd, _ := os.Getwd()
-	files, err := os.ReadDir(cwd)
+// InitPlan lists what init changes in a catalog.
+type InitPlan struct {
+	Kept    []CatEntry // the catalog after init, new entries last
+	Added   []CatEntry // new files, tagged by the folder's .catrules
+	Removed []CatEntry // entries whose file vanished
+}
+
+// PlanInit compares entries with the files in dir, leaving out the
+// [catalog] ignore patterns. URL entries never appear in the directory
+// listing and are always kept.
+func PlanInit(dir string, entries []CatEntry) (*InitPlan, error) {
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := config.LoadDir(dir)
 	if err != nil {
-		fmt.Printf("init error: %v\n", err)
-		return
+		return nil, err
 	}
 	fileSet := make(map[string]struct{})
+	var names []string
 	for _, f := range files {
 		name := f.Name()
-		if len(name) != 0 && name[0] != '.' && !f.IsDir() {
+		if len(name) != 0 && name[0] != '.' && !f.IsDir() && !cfg.Ignored(name) {
 			fileSet[name] = struct{}{}
+			names = append(names, name)
 		}
 	}
-
-	// Load .cat
-	entries, err := LoadCatalog()
-	if err != nil {
-		fmt.Printf("init error loading .cat: %v\n", err)
-		return
-	}
-	// Keep entries whose file exists
-	var kept []CatEntry
+	plan := &InitPlan{}
 	seen := make(map[string]struct{})
 	for _, e := range entries {
-		if _, ok := fileSet[e.Name]; ok {
-			kept = append(kept, e)
+		_, ok := fileSet[e.Name]
+		if ok || e.Type == "url" {
+			plan.Kept = append(plan.Kept, e)
 			seen[e.Name] = struct{}{}
+		} else {
+			plan.Removed = append(plan.Removed, e)
+		}
+	}
+	ruleSet, err := rules.Load(dir)
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if _, ok := seen[name]; ok {
+			continue
+		}
+		e := CatEntry{Name: name, Type: "file"}
+		for _, t := range append(append([]string{}, ruleSet.Defaults...), ruleSet.Match(dir, name)...) {
+			if !containsTag(e.Tags, t) {
+				e.Tags = append(e.Tags, t)
+			}
+		}
+		plan.Added = append(plan.Added, e)
+		plan.Kept = append(plan.Kept, e)
+	}
+	return plan, nil
+}
+
+// CmdInitCatalog: synchronize .cat with directory files (add new, remove vanished)
+// A missing .cat is created rather than reported as ErrNoCatalog, as the
+// README describes. init -n only shows what would change. If more than [init] confirm_percent
+// of the entries would be removed (a folder that is not mounted or only half
+// synced), init asks first.
+func CmdInitCatalog(w io.Writer, args ...string) error {
+	dryRun := false
+	for _, a := range args {
+		switch a {
+		case "-n", "--dry-run":
+			dryRun = true
+		default:
+			return fmt.Errorf("usage: init [-n]")
+		}
+	}
+	Lock()
+	defer Unlock()
+	cwd, _ := os.Getwd()
+	var entries []CatEntry
+	if _, err := os.Stat(CatalogFilename); err == nil {
+		if entries, err = LoadCatalog(); err != nil {
+			return fmt.Errorf("init error loading .cat: %w", err)
+		}
+	}
+	plan, err := PlanInit(cwd, entries)
+	if err != nil {
+		return fmt.Errorf("init error: %w", err)
+	}
+	added, removed := len(plan.Added), len(plan.Removed)
+	if dryRun {
+		for _, e := range plan.Added {
+			fmt.Fprintln(w, "+ "+describeEntry(e))
+		}
+		for _, e := range plan.Removed {
+			fmt.Fprintln(w, "- "+describeEntry(e))
+		}
+		fmt.Fprintf(w, ".cat would change: %d added, %d removed (dry run, nothing saved)\n", added, removed)
+		return nil
+	}
+	if removed > 0 {
+		cfg, err := config.Load()
+		if err != nil {
+			fmt.Fprintln(w, "config error:", err)
+		}
+		if removed*100 > cfg.Init.ConfirmPercent*len(entries) {
+			fmt.Fprintf(w, "init would remove %d of %d entries. Is the folder complete (mounted, synced)?\n", removed, len(entries))
+			for i, e := range plan.Removed {
+				if i == 10 {
+					fmt.Fprintf(w, "  ... and %d more (see 'init -n')\n", removed-i)
+					break
+				}
+				fmt.Fprintln(w, "  - "+describeEntry(e))
+			}
+			if !Confirm(w, "Remove them?") {
+				fmt.Fprintln(w, "init cancelled, .cat unchanged")
+				return nil
+			}
 		}
 	}
-	removed := len(entries) - len(kept)
 
-	// Add new file entries
-	added := 0
-	for name := range fileSet {
-		if _, ok := seen[name]; !ok {
-			kept = append(kept, CatEntry{Name: name, Type: "file"})
-			added++
+	tagged := 0
+	for _, e := range plan.Added {
+		if len(e.Tags) > 0 {
+			tagged++
 		}
 	}
-	SaveCatalog(kept)
-	fmt.Printf(".cat synchronized: %d added, %d removed\n", added, removed)
+	// Vanished entries are tombstoned so 'restore' can bring their tags back.
+	if err := SaveRemoval(Source{Dir: cwd, File: CatalogFilename}, entries, plan.Kept, Vanished(plan.Removed)); err != nil {
+		return fmt.Errorf("init error: %w", err)
+	}
+	fmt.Fprintf(w, ".cat synchronized: %d added, %d removed\n", added, removed)
+	if removed > 0 {
+		fmt.Fprintln(w, "removed entries keep their tags in the trash, see 'trash ls'")
+	}
+	if tagged > 0 {
+		fmt.Fprintf(w, "%d new entries tagged by %s\n", tagged, rules.Filename)
+	}
+	return nil
+}
+
+// describeEntry returns the name of e followed by its tags.
+func describeEntry(e CatEntry) string {
+	if len(e.Tags) == 0 {
+		return e.Name
+	}
+	return e.Name + " (" + strings.Join(e.Tags, ", ") + ")"
 }
 
 // LoadCatalogAt loads catalog entries from a given filepath.
@@ -342,3 +541,57 @@ func LoadCatalogAt(filename string) ([]CatEntry, error) {
 	}
 	return entries, nil
 }
+
+// Source is a catalog file together with the folder its entry names refer to.
+type Source struct {
+	Dir  string
+	File string
+}
+
+// ResolveSources returns the catalogs visible from dir: its own .cat if
+// present, otherwise every existing .cat listed in its .catlink.
+func ResolveSources(dir string) ([]Source, error) {
+	isFile := func(file string) bool {
+		s, err := os.Stat(file)
+		return err == nil && !s.IsDir()
+	}
+	catPath := filepath.Join(dir, CatalogFilename)
+	if isFile(catPath) {
+		return []Source{{Dir: dir, File: catPath}}, nil
+	}
+	linkPath := filepath.Join(dir, ".catlink")
+	if !isFile(linkPath) {
+		return nil, fmt.Errorf("%w (.cat or .catlink) in %s", ErrNoCatalog, dir)
+	}
+	f, err := os.Open(linkPath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var sources []Source
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		linked := strings.TrimSpace(scanner.Text())
+		if linked == "" {
+			continue
+		}
+		catfile := filepath.Join(linked, ".cat")
+		if isFile(catfile) {
+			sources = append(sources, Source{Dir: linked, File: catfile})
+		}
+	}
+	return sources, scanner.Err()
+}
+
+// EntryPath returns the absolute path of a file entry inside dir, or the
+// name itself for URL entries.
+func EntryPath(dir string, e CatEntry) string {
+	if e.Type != "file" || filepath.IsAbs(e.Name) {
+		return e.Name
+	}
+	abs, err := filepath.Abs(filepath.Join(dir, e.Name))
+	if err != nil {
+		return filepath.Join(dir, e.Name)
+	}
+	return abs
+}
@@ -1,8 +1,16 @@
 package catalog
 
 import (
+    "bytes"
+    "errors"
+    "io"
     "os"
+    "path/filepath"
+    "runtime"
+    "strings"
     "testing"
+
+    "github.com/tenzokai/filemac/internal/testutil"
 )
 
 func TestParseCatalogLine(t *testing.T) {
@@ -48,3 +56,447 @@ func TestCatalogSaveLoadRoundtrip(t *testing.T) {
         t.Error("Tags not roundtripped")
     }
 }
+
+func TestNormalizeURL(t *testing.T) {
+    cases := map[string]string{
+        "example.org/":                                   "https://example.org",
+        "HTTPS://Example.ORG:443/a/b/?utm_source=x&id=2": "https://example.org/a/b?id=2",
+        "http://example.org:8080/x?fbclid=1#top":         "http://example.org:8080/x#top",
+    }
+    for in, want := range cases {
+        got, err := NormalizeURL(in)
+        if err != nil || got != want {
+            t.Errorf("NormalizeURL(%q) = %q, %v; want %q", in, got, err, want)
+        }
+    }
+    if _, err := NormalizeURL("ftp://example.org"); err == nil {
+        t.Error("expected error for unsupported scheme")
+    }
+}
+
+func TestAddURLAndInitKeepsURLs(t *testing.T) {
+    t.Chdir(t.TempDir())
+    os.WriteFile("a.pdf", []byte("x"), 0644)
+    SaveCatalog(nil)
+    if err := CmdAddURL(io.Discard, []string{"https://example.org/?utm_medium=mail", "bank", "-t", "Online", "Banking"}); err != nil {
+        t.Fatal(err)
+    }
+    if err := CmdAddURL(io.Discard, []string{"example.org/"}); err == nil {
+        t.Error("duplicate URL accepted")
+    }
+    CmdInitCatalog(io.Discard)
+    entries, _ := LoadCatalog()
+    if len(entries) != 2 {
+        t.Fatalf("expected url and file entry, got %+v", entries)
+    }
+    u := entries[0]
+    if u.Name != "https://example.org" || u.Type != "url" || u.Title != "Online Banking" || len(u.Tags) != 1 {
+        t.Errorf("unexpected url entry: %+v", u)
+    }
+}
+
+func TestInitCreatesCatalog(t *testing.T) {
+    t.Chdir(t.TempDir())
+    t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+    os.WriteFile("a.pdf", []byte("x"), 0644)
+    if _, err := LoadCatalog(); !errors.Is(err, ErrNoCatalog) {
+        t.Fatalf("LoadCatalog without .cat = %v", err)
+    }
+    if err := CmdInitCatalog(io.Discard, "-n"); err != nil {
+        t.Fatal(err)
+    }
+    if _, err := os.Stat(CatalogFilename); !os.IsNotExist(err) {
+        t.Error("dry run created .cat")
+    }
+    var out bytes.Buffer
+    if err := CmdInitCatalog(&out); err != nil || out.String() != ".cat synchronized: 1 added, 0 removed\n" {
+        t.Errorf("init = %v, %q", err, out.String())
+    }
+    if entries, err := LoadCatalog(); err != nil || len(entries) != 1 || entries[0].Name != "a.pdf" {
+        t.Errorf("created catalog: %+v, %v", entries, err)
+    }
+}
+
+func TestNoteRoundtrip(t *testing.T) {
+    e := CatEntry{Name: "vertrag.pdf", Tags: []string{"miete"}, Note: "Kündigungsfrist *3* Monate"}
+    got := ParseCatalogLine(FormatCatalogLine(e))
+    if got.Note != "Kündigungsfrist  3  Monate" || len(got.Tags) != 1 {
+        t.Errorf("unexpected roundtrip: %+v", got)
+    }
+}
+
+func TestCheckTag(t *testing.T) {
+    for _, tag := range []string{"@title=x", "@note="} {
+        if err := CheckTag(tag); !errors.Is(err, ErrFieldTag) {
+            t.Errorf("CheckTag(%q) = %v", tag, err)
+        }
+    }
+    for _, tag := range []string{"@home", "title=x", "steuer"} {
+        e := CatEntry{Name: "a.pdf", Tags: []string{tag}}
+        if err := CheckTag(tag); err != nil || ParseCatalogLine(FormatCatalogLine(e)).Tags[0] != tag {
+            t.Errorf("tag %q: %v", tag, err)
+        }
+    }
+}
+
+func TestDateFromName(t *testing.T) {
+    for name, want := range map[string]string{
+        "2007-07-11_Jakob.pdf": "2007-07-11",
+        "20240502 scan.pdf":    "2024-05-02",
+        "report.pdf":           "",
+        "2024-13-01_x.pdf":     "",
+    } {
+        d, ok := DateFromName(name)
+        got := ""
+        if ok {
+            got = d.Format("2006-01-02")
+        }
+        if got != want {
+            t.Errorf("DateFromName(%q) = %q, want %q", name, got, want)
+        }
+    }
+}
+
+func TestInitAppliesRules(t *testing.T) {
+    t.Chdir(t.TempDir())
+    os.WriteFile("Allianz_Police.pdf", []byte("x"), 0644)
+    os.WriteFile(".catrules", []byte("*allianz* -> versicherung\ndefault -> neu\n"), 0644)
+    SaveCatalog(nil)
+    CmdInitCatalog(io.Discard)
+    entries, _ := LoadCatalog()
+    if len(entries) != 1 || strings.Join(entries[0].Tags, ",") != "neu,versicherung" {
+        t.Errorf("unexpected entries: %+v", entries)
+    }
+}
+
+func TestRenameEntriesSwapAndConflicts(t *testing.T) {
+    dir := t.TempDir()
+    entries := []CatEntry{
+        {Name: "a.pdf", Type: "file", Tags: []string{"x"}},
+        {Name: "b.pdf", Type: "file", Tags: []string{"y"}},
+        {Name: "c.pdf", Type: "file"},
+    }
+    for _, e := range entries {
+        os.WriteFile(dir+"/"+e.Name, []byte(e.Name), 0644)
+    }
+    os.WriteFile(dir+"/other.pdf", []byte("other"), 0644)
+
+    if err := RenameEntries(dir, entries, map[int]string{2: "other.pdf"}); err == nil {
+        t.Error("overwrote an existing file")
+    }
+    if err := RenameEntries(dir, entries, map[int]string{0: "z.pdf", 1: "z.pdf"}); err == nil {
+        t.Error("accepted two entries with the same new name")
+    }
+    plan := map[int]string{0: "b.pdf", 1: "c.pdf", 2: "a.pdf"}
+    if got := describeCycles(entries, plan); len(got) != 1 || !strings.HasPrefix(got[0], "cycle:") {
+        t.Errorf("describeCycles = %q", got)
+    }
+    if err := RenameEntries(dir, entries, plan); err != nil {
+        t.Fatalf("cycle rename: %v", err)
+    }
+    if data, _ := os.ReadFile(dir + "/b.pdf"); string(data) != "a.pdf" || entries[0].Name != "b.pdf" || entries[0].Tags[0] != "x" {
+        t.Errorf("b.pdf holds %q, entry %+v", data, entries[0])
+    }
+
+    for _, name := range []string{"", ".hidden", "a*b.pdf", "12:30 Termin.pdf", "sub/x.pdf"} {
+        if err := CheckName(name); err == nil {
+            t.Errorf("CheckName(%q) accepted", name)
+        }
+    }
+    if err := RenameEntries(dir, entries, map[int]string{2: "a:b.pdf"}); err == nil {
+        t.Error("renamed to a name with ':'")
+    }
+
+    text := "1\tb.pdf\n2\tnew name.pdf\n"
+    plan, err := ParseRenameList(text, entries)
+    if err != nil || len(plan) != 1 || plan[1] != "new name.pdf" {
+        t.Errorf("ParseRenameList = %v, %v", plan, err)
+    }
+    // a rename onto a name that stays, listed or not
+    for _, text := range []string{"2\ta.pdf\n3\ta.pdf\n", "2\ta.pdf\n"} {
+        if plan, err := ParseRenameList(text, entries); err == nil {
+            t.Errorf("ParseRenameList(%q) = %v, want conflict", text, plan)
+        }
+    }
+}
+
+func TestTrashRestorePurge(t *testing.T) {
+    dir := t.TempDir()
+    t.Chdir(dir)
+    os.WriteFile("a.pdf", []byte("a"), 0644)
+    os.WriteFile("b.pdf", []byte("b"), 0644)
+    SaveCatalog([]CatEntry{
+        {Name: "a.pdf", Type: "file", Tags: []string{"steuer"}},
+        {Name: "b.pdf", Type: "file", Tags: []string{"bank"}},
+    })
+    CmdRemove(io.Discard, []string{"1"})
+    entries, _ := LoadCatalog()
+    if len(entries) != 1 || entries[0].Name != "b.pdf" {
+        t.Fatalf("after rm: %+v", entries)
+    }
+    if _, err := os.Stat("a.pdf"); !os.IsNotExist(err) {
+        t.Error("a.pdf not moved to trash")
+    }
+    // b.pdf vanishes behind filemac's back
+    os.Remove("b.pdf")
+    testutil.WithStdin(t, "y\n", func() { CmdInitCatalog(io.Discard) })
+    ts, err := LoadTombstones(dir)
+    if err != nil || len(ts) != 2 || ts[0].Stored == "" || ts[1].Stored != "" || ts[1].Entry.Tags[0] != "bank" {
+        t.Fatalf("tombstones: %+v, %v", ts, err)
+    }
+
+    // b.pdf is still missing and stays in the trash
+    if err := CmdRestore(io.Discard, []string{"1-2"}); err == nil || !strings.Contains(err.Error(), "b.pdf vanished") {
+        t.Errorf("restore of a vanished file: %v", err)
+    }
+    entries, _ = LoadCatalog()
+    if len(entries) != 1 || entries[0].Name != "a.pdf" || entries[0].Tags[0] != "steuer" {
+        t.Fatalf("after restore: %+v", entries)
+    }
+    if data, _ := os.ReadFile("a.pdf"); string(data) != "a" {
+        t.Error("a.pdf not restored")
+    }
+    if n, err := PurgeTrash(dir, 0); err != nil || n != 1 {
+        t.Errorf("PurgeTrash = %d, %v", n, err)
+    }
+    if _, err := ParseSelector([]string{"1,3-4"}, 3); err == nil {
+        t.Error("selector beyond the catalog accepted")
+    }
+    if d, err := ParseAge("90d"); err != nil || d.Hours() != 90*24 {
+        t.Errorf("ParseAge = %v, %v", d, err)
+    }
+}
+
+func TestRestoreKeepsTombstonesWhenSaveFails(t *testing.T) {
+    dir := t.TempDir()
+    t.Chdir(dir)
+    os.WriteFile("a.pdf", []byte("a"), 0644)
+    _, ts, err := TrashEntries(dir, []CatEntry{{Name: "a.pdf", Type: "file", Tags: []string{"steuer"}}}, []int{0}, false)
+    if err != nil || SaveTombstones(dir, ts) != nil {
+        t.Fatal(err)
+    }
+    // a catalog name this long can be read, but the temporary file next to
+    // it cannot be created, so saving fails
+    long := strings.Repeat("c", 250)
+    os.WriteFile(long, []byte("b.pdf*bank\n"), 0644)
+    defer func(name string) { CatalogFilename = name }(CatalogFilename)
+    CatalogFilename = long
+
+    if err := CmdRestore(io.Discard, []string{"1"}); err == nil || !strings.Contains(err.Error(), "saving catalog") {
+        t.Fatalf("restore with a failing save: %v", err)
+    }
+    if ts, err := LoadTombstones(dir); err != nil || len(ts) != 1 || ts[0].Entry.Tags[0] != "steuer" {
+        t.Fatalf("tombstone lost after failed save: %+v, %v", ts, err)
+    }
+
+    // once the catalog can be saved, the restore can be repeated
+    os.Rename(long, ".cat")
+    CatalogFilename = ".cat"
+    if err := CmdRestore(io.Discard, []string{"1"}); err != nil {
+        t.Fatalf("second restore: %v", err)
+    }
+    entries, _ := LoadCatalog()
+    if len(entries) != 2 || entries[1].Name != "a.pdf" || entries[1].Tags[0] != "steuer" {
+        t.Errorf("after restore: %+v", entries)
+    }
+    if ts, _ := LoadTombstones(dir); len(ts) != 0 {
+        t.Errorf("tombstones left: %+v", ts)
+    }
+}
+
+func TestRemoveSavesCatalogBeforeTombstones(t *testing.T) {
+    dir := t.TempDir()
+    t.Chdir(dir)
+    home := t.TempDir()
+    t.Setenv("XDG_CONFIG_HOME", home)
+    t.Setenv("XDG_DATA_HOME", t.TempDir())
+    os.WriteFile("a.pdf", []byte("a"), 0644)
+    os.WriteFile("b.pdf", []byte("b"), 0644)
+
+    // the catalog cannot be saved (see above): the file comes back
+    long := strings.Repeat("c", 250)
+    os.WriteFile(long, []byte("a.pdf*steuer\nb.pdf*bank\n"), 0644)
+    defer func(name string) { CatalogFilename = name }(CatalogFilename)
+    CatalogFilename = long
+    if err := CmdRemove(io.Discard, []string{"1"}); err == nil || !strings.Contains(err.Error(), "saving catalog") {
+        t.Fatalf("rm with a failing catalog save: %v", err)
+    }
+    if _, err := os.Stat("a.pdf"); err != nil {
+        t.Error("a.pdf left in the trash")
+    }
+    if ts, _ := LoadTombstones(dir); len(ts) != 0 {
+        t.Errorf("tombstones for entries still listed: %+v", ts)
+    }
+    os.Rename(long, ".cat")
+    CatalogFilename = ".cat"
+
+    // the tombstones cannot be saved: nothing may be created in /proc
+    if _, err := os.Stat("/proc/self"); err != nil {
+        t.Skip("needs /proc")
+    }
+    os.Remove(TrashDirname)
+    if err := os.Symlink("/proc/self", TrashDirname); err != nil {
+        t.Fatal(err)
+    }
+    os.Remove("b.pdf")
+    testutil.WithStdin(t, "y\n", func() {
+        if err := CmdInitCatalog(io.Discard); err == nil || !strings.Contains(err.Error(), "trash") {
+            t.Errorf("init with failing tombstones: %v", err)
+        }
+    })
+    if entries, _ := LoadCatalog(); len(entries) != 2 || entries[1].Tags[0] != "bank" {
+        t.Errorf("vanished entry dropped without a tombstone: %+v", entries)
+    }
+    if runtime.GOOS == "linux" {
+        // the freedesktop trash still works, so the file is moved and back
+        os.MkdirAll(filepath.Join(home, "filemac"), 0755)
+        os.WriteFile(filepath.Join(home, "filemac", "config.toml"), []byte("[trash]\nsystem = true\n"), 0644)
+        if err := CmdRemove(io.Discard, []string{"1"}); err == nil || !strings.Contains(err.Error(), "trash") {
+            t.Errorf("rm with failing tombstones: %v", err)
+        }
+        if data, _ := os.ReadFile("a.pdf"); string(data) != "a" {
+            t.Error("a.pdf not moved back")
+        }
+        if entries, _ := LoadCatalog(); len(entries) != 2 {
+            t.Errorf("catalog not put back: %+v", entries)
+        }
+    }
+
+    os.Remove(TrashDirname)
+    testutil.WithStdin(t, "y\n", func() { CmdInitCatalog(io.Discard) })
+    if ts, err := LoadTombstones(dir); err != nil || len(ts) != 1 || ts[0].Entry.Name != "b.pdf" {
+        t.Errorf("tombstones after retry: %+v, %v", ts, err)
+    }
+}
+
+func TestCheckAndTidy(t *testing.T) {
+    dir := t.TempDir()
+    linked := t.TempDir()
+    os.WriteFile(dir+"/a.pdf", []byte("a"), 0644)
+    os.WriteFile(dir+"/.cat", []byte("a.pdf*steuer *x\na.pdf*2024*@note=paid\ngone.pdf*bank\n"), 0644)
+    os.WriteFile(dir+"/.catlink", []byte(linked+"\n"+dir+"/nowhere\n"), 0644)
+    os.WriteFile(linked+"/.cat", []byte("b.pdf*x\n"), 0644)
+    os.WriteFile(linked+"/b.pdf", []byte("b"), 0644)
+
+    sources, problems, err := CheckSources(dir)
+    if err != nil || len(sources) != 2 || len(problems) != 1 || problems[0].Kind != ProblemDeadLink {
+        t.Fatalf("CheckSources = %v, %v, %v", sources, problems, err)
+    }
+    counts := map[string]int{}
+    for _, src := range sources {
+        found, err := CheckCatalog(src)
+        if err != nil {
+            t.Fatal(err)
+        }
+        for _, p := range found {
+            counts[p.Kind]++
+        }
+    }
+    if counts[ProblemDuplicate] != 1 || counts[ProblemSpaces] != 1 || counts[ProblemMissing] != 1 {
+        t.Errorf("counts = %v", counts)
+    }
+
+    entries, _ := LoadCatalogAt(dir + "/.cat")
+    tidy := Tidy(entries)
+    if len(tidy) != 2 || strings.Join(tidy[0].Tags, ",") != "steuer,x,2024" || tidy[0].Note != "paid" {
+        t.Errorf("Tidy = %+v", tidy)
+    }
+    if FormatCatalogLine(tidy[0]) != "a.pdf*steuer*x*2024*@note=paid" {
+        t.Errorf("tidied line = %q", FormatCatalogLine(tidy[0]))
+    }
+}
+
+func TestInitDryRunAndConfirm(t *testing.T) {
+    t.Chdir(t.TempDir())
+    t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+    os.WriteFile("a.pdf", []byte("a"), 0644)
+    os.WriteFile("new.pdf", []byte("n"), 0644)
+    SaveCatalog([]CatEntry{
+        {Name: "a.pdf", Type: "file", Tags: []string{"x"}},
+        {Name: "b.pdf", Type: "file", Tags: []string{"y"}},
+    })
+    before, _ := os.ReadFile(CatalogFilename)
+
+    var out bytes.Buffer
+    CmdInitCatalog(&out, "-n")
+    if !strings.Contains(out.String(), "+ new.pdf\n- b.pdf (y)\n") {
+        t.Errorf("dry run output:\n%s", out.String())
+    }
+    testutil.WithStdin(t, "n\n", func() { CmdInitCatalog(io.Discard) })
+    if after, _ := os.ReadFile(CatalogFilename); string(after) != string(before) {
+        t.Fatalf("catalog changed:\n%s", after)
+    }
+    plan, err := PlanInit(".", nil)
+    if err != nil || len(plan.Added) != 2 || plan.Added[0].Name != "a.pdf" {
+        t.Errorf("PlanInit = %+v, %v", plan, err)
+    }
+
+    testutil.WithStdin(t, "y\n", func() { CmdInitCatalog(io.Discard) })
+    entries, _ := LoadCatalog()
+    if len(entries) != 2 || entries[0].Name != "a.pdf" || entries[1].Name != "new.pdf" {
+        t.Errorf("after init: %+v", entries)
+    }
+}
+
+func TestSelectEntries(t *testing.T) {
+    dir := t.TempDir()
+    entries := []CatEntry{
+        {Name: "2024-05-02_Bescheid.pdf", Type: "file", Tags: []string{"steuer", "2024"}},
+        {Name: "Brief.txt", Type: "file", Tags: []string{"steuer"}},
+        {Name: "2019-01-01_alt.PDF", Type: "file"},
+        {Name: "https://example.org", Type: "url", Tags: []string{"steuer"}},
+    }
+    for _, e := range entries[:3] {
+        os.WriteFile(dir+"/"+e.Name, []byte("x"), 0644)
+    }
+    os.Remove(dir + "/Brief.txt")
+
+    check := func(args string, want ...int) {
+        t.Helper()
+        o, err := ParseViewArgs(strings.Fields(args))
+        if err != nil {
+            t.Fatalf("%s: %v", args, err)
+        }
+        got := SelectEntries(dir, entries, o)
+        if len(got) != len(want) {
+            t.Errorf("%s = %v, want %v", args, got, want)
+            return
+        }
+        for i := range got {
+            if got[i] != want[i] {
+                t.Errorf("%s = %v, want %v", args, got, want)
+                return
+            }
+        }
+    }
+    check("-tag steuer", 0, 1, 3)
+    check("-tag steuer -tag 2024", 0)
+    check("-ext pdf -sort date", 2, 0)
+    check("-missing", 1)
+    check("-new", 2)
+    check("-sort name", 2, 0, 1, 3)
+    check("-since 2020-01-01 -ext .pdf", 0)
+    if _, err := ParseViewArgs([]string{"-sort", "size"}); err == nil {
+        t.Error("unknown sort key accepted")
+    }
+}
+
+func TestCommandErrors(t *testing.T) {
+    t.Chdir(t.TempDir())
+    if err := CmdNote(io.Discard, "1", nil); !errors.Is(err, ErrNoCatalog) {
+        t.Errorf("note without catalog: %v", err)
+    }
+    SaveCatalog([]CatEntry{{Name: "a.pdf", Type: "file"}})
+    for _, num := range []string{"0", "2", "x"} {
+        if err := CmdNote(io.Discard, num, []string{"text"}); !errors.Is(err, ErrBadIndex) {
+            t.Errorf("note %s: %v", num, err)
+        }
+    }
+    if err := CmdRemove(io.Discard, []string{"1-3"}); !errors.Is(err, ErrBadIndex) {
+        t.Errorf("rm 1-3: %v", err)
+    }
+    var out bytes.Buffer
+    if err := CmdNote(&out, "1", []string{"call", "back"}); err != nil || out.String() != "note set on entry 1\n" {
+        t.Errorf("note = %v, %q", err, out.String())
+    }
+}
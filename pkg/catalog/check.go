@@ -0,0 +1,275 @@
+package catalog
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Problem classes reported by check, in the order they are listed.
+const (
+	ProblemDuplicate = "duplicate lines"
+	ProblemSpaces    = "stray spaces in names or tags"
+	ProblemMissing   = "entries with missing files"
+	ProblemDeadLink  = "dead .catlink targets"
+)
+
+var problemOrder = []string{ProblemDuplicate, ProblemSpaces, ProblemMissing, ProblemDeadLink}
+
+// Problem is one inconsistency found in a catalog or .catlink file.
+type Problem struct {
+	Kind string
+	File string // the .cat or .catlink file
+	Name string // entry name, or the link target
+}
+
+// CheckSources returns the catalogs check looks at in dir: its own .cat and
+// the .cat of every .catlink target. Targets without a .cat are returned as
+// dead links.
+func CheckSources(dir string) ([]Source, []Problem, error) {
+	var sources []Source
+	var dead []Problem
+	if fi, err := os.Stat(filepath.Join(dir, CatalogFilename)); err == nil && !fi.IsDir() {
+		sources = append(sources, Source{Dir: dir, File: filepath.Join(dir, CatalogFilename)})
+	}
+	linkPath := filepath.Join(dir, ".catlink")
+	links, err := readLinks(linkPath)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, nil, err
+	}
+	for _, linked := range links {
+		catfile := filepath.Join(linked, CatalogFilename)
+		if fi, err := os.Stat(catfile); err != nil || fi.IsDir() {
+			dead = append(dead, Problem{Kind: ProblemDeadLink, File: linkPath, Name: linked})
+			continue
+		}
+		sources = append(sources, Source{Dir: linked, File: catfile})
+	}
+	if len(sources) == 0 && len(dead) == 0 {
+		return nil, nil, fmt.Errorf("%w (.cat or .catlink) in %s", ErrNoCatalog, dir)
+	}
+	return sources, dead, nil
+}
+
+// readLinks returns the non-empty lines of a .catlink file.
+func readLinks(linkPath string) ([]string, error) {
+	f, err := os.Open(linkPath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var links []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if linked := strings.TrimSpace(scanner.Text()); linked != "" {
+			links = append(links, linked)
+		}
+	}
+	return links, scanner.Err()
+}
+
+// CheckCatalog reports duplicate lines, stray spaces and missing files in
+// the catalog of src. Spaces are looked for in the raw lines, since parsing
+// already trims tags.
+func CheckCatalog(src Source) ([]Problem, error) {
+	entries, err := LoadCatalogAt(src.File)
+	if err != nil {
+		return nil, err
+	}
+	var out []Problem
+	seen := map[string]bool{}
+	for _, e := range entries {
+		name := strings.TrimSpace(e.Name)
+		if seen[name] {
+			out = append(out, Problem{Kind: ProblemDuplicate, File: src.File, Name: name})
+		}
+		seen[name] = true
+		for _, field := range strings.Split(e.Raw, "*") {
+			if field != strings.TrimSpace(field) {
+				out = append(out, Problem{Kind: ProblemSpaces, File: src.File, Name: name})
+				break
+			}
+		}
+		if e.Type == "file" && !exists(EntryPath(src.Dir, CatEntry{Name: name, Type: "file"})) {
+			out = append(out, Problem{Kind: ProblemMissing, File: src.File, Name: name})
+		}
+	}
+	return out, nil
+}
+
+// Tidy trims names and merges duplicate entries into the first one, keeping
+// all tags and the first title and note. It returns the tidied entries.
+func Tidy(entries []CatEntry) []CatEntry {
+	var out []CatEntry
+	pos := map[string]int{}
+	for _, e := range entries {
+		e.Name = strings.TrimSpace(e.Name)
+		e.Raw = ""
+		i, dup := pos[e.Name]
+		if !dup {
+			pos[e.Name] = len(out)
+			out = append(out, e)
+			continue
+		}
+		for _, t := range e.Tags {
+			if !containsTag(out[i].Tags, t) {
+				out[i].Tags = append(out[i].Tags, t)
+			}
+		}
+		if out[i].Title == "" {
+			out[i].Title = e.Title
+		}
+		if out[i].Note == "" {
+			out[i].Note = e.Note
+		}
+	}
+	return out
+}
+
+// fixCatalog tidies the catalog of src and, with remove set, moves the
+// entries with missing files to the trash. It returns the number of
+// entries left.
+func fixCatalog(src Source, remove bool) (int, error) {
+	Lock()
+	defer Unlock()
+	entries, err := LoadCatalogAt(src.File)
+	if err != nil {
+		return 0, err
+	}
+	fixed := Tidy(entries)
+	var vanished []CatEntry
+	if remove {
+		var kept []CatEntry
+		for _, e := range fixed {
+			if e.Type == "file" && !exists(EntryPath(src.Dir, e)) {
+				vanished = append(vanished, e)
+			} else {
+				kept = append(kept, e)
+			}
+		}
+		fixed = kept
+	}
+	if err := SaveRemoval(src, entries, fixed, Vanished(vanished)); err != nil {
+		return 0, err
+	}
+	return len(fixed), nil
+}
+
+// Confirm writes a yes/no question to w and reads the answer from stdin;
+// anything but y or yes is no. It reads byte by byte so no input meant for
+// later prompts is consumed.
+func Confirm(w io.Writer, question string) bool {
+	fmt.Fprintf(w, "%s [y/N] ", question)
+	var answer []byte
+	b := make([]byte, 1)
+	for {
+		n, err := os.Stdin.Read(b)
+		if n == 0 || err != nil {
+			fmt.Fprintln(w)
+			break
+		}
+		if b[0] == '\n' {
+			break
+		}
+		answer = append(answer, b[0])
+	}
+	switch strings.ToLower(strings.TrimSpace(string(answer))) {
+	case "y", "yes":
+		return true
+	}
+	return false
+}
+
+// CmdCheck looks for problems in the local catalog and all linked catalogs
+// and prints them with counts per class: check [--fix]. With --fix
+// duplicates and stray spaces are repaired right away; removing entries of
+// missing files and dead .catlink targets is asked for first.
+func CmdCheck(w io.Writer, args []string) error {
+	fix := false
+	for _, a := range args {
+		switch a {
+		case "--fix", "-fix":
+			fix = true
+		default:
+			return fmt.Errorf("usage: check [--fix]")
+		}
+	}
+	cwd, _ := os.Getwd()
+	sources, problems, err := CheckSources(cwd)
+	if err != nil {
+		return err
+	}
+	for _, src := range sources {
+		found, err := CheckCatalog(src)
+		if err != nil {
+			return fmt.Errorf("check: %w", err)
+		}
+		problems = append(problems, found...)
+	}
+
+	counts := map[string]int{}
+	for _, p := range problems {
+		counts[p.Kind]++
+		fmt.Fprintf(w, "%s: %s (%s)\n", p.File, p.Name, p.Kind)
+	}
+	fmt.Fprintf(w, "Checked %d catalogs:\n", len(sources))
+	for _, kind := range problemOrder {
+		fmt.Fprintf(w, "  %-30s %d\n", kind+":", counts[kind])
+	}
+	if len(problems) == 0 || !fix {
+		if len(problems) > 0 {
+			fmt.Fprintln(w, "Run 'check --fix' to repair them.")
+		}
+		return nil
+	}
+
+	// a catalog that cannot be fixed does not keep the others from being fixed
+	var failed error
+	for _, src := range sources {
+		found, missing := 0, 0
+		for _, p := range problems {
+			if p.File != src.File {
+				continue
+			}
+			found++
+			if p.Kind == ProblemMissing {
+				missing++
+			}
+		}
+		if found == 0 {
+			continue
+		}
+		remove := missing > 0 && Confirm(w, fmt.Sprintf("Remove %d entries with missing files from %s (tags are kept in the trash)?", missing, src.File))
+		n, err := fixCatalog(src, remove)
+		if err != nil {
+			failed = fmt.Errorf("check: %w", err)
+			continue
+		}
+		fmt.Fprintf(w, "%s: %d entries left\n", src.File, n)
+	}
+
+	if counts[ProblemDeadLink] > 0 && Confirm(w, fmt.Sprintf("Remove %d dead targets from .catlink?", counts[ProblemDeadLink])) {
+		linkPath := filepath.Join(cwd, ".catlink")
+		links, _ := readLinks(linkPath)
+		dead := map[string]bool{}
+		for _, p := range problems {
+			if p.Kind == ProblemDeadLink {
+				dead[p.Name] = true
+			}
+		}
+		var kept []string
+		for _, l := range links {
+			if !dead[l] {
+				kept = append(kept, l+"\n")
+			}
+		}
+		if err := os.WriteFile(linkPath, []byte(strings.Join(kept, "")), 0644); err != nil {
+			return fmt.Errorf("check: %w", err)
+		}
+		fmt.Fprintf(w, ".catlink now lists %d folders\n", len(kept))
+	}
+	return failed
+}
@@ -0,0 +1,25 @@
+package catalog
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+// Errors returned by the catalog commands. They come wrapped with details,
+// so check for them with errors.Is.
+var (
+	ErrNoCatalog = errors.New("no catalog")
+	ErrBadIndex  = errors.New("invalid entry number")
+	ErrFieldTag  = errors.New("tag would be read back as a field")
+)
+
+// EntryIndex parses the entry number num (as numbered in 'vc') of a catalog
+// with n entries and returns its index.
+func EntryIndex(num string, n int) (int, error) {
+	i, err := strconv.Atoi(num)
+	if err != nil || i < 1 || i > n {
+		return 0, fmt.Errorf("%w: %s", ErrBadIndex, num)
+	}
+	return i - 1, nil
+}
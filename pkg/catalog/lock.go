@@ -0,0 +1,15 @@
+package catalog
+
+import "sync"
+
+// lock serializes the read-modify-write cycles on catalog files of the
+// background watcher and the commands run in the shell, so neither saves
+// over changes the other made in between.
+var lock sync.Mutex
+
+// Lock takes the catalog lock. Hold it from loading a catalog until the
+// changed catalog is saved.
+func Lock() { lock.Lock() }
+
+// Unlock releases the catalog lock.
+func Unlock() { lock.Unlock() }
@@ -0,0 +1,128 @@
+package catalog
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/tenzokai/filemac/pkg/pdf"
+)
+
+// namedDate matches a leading 2024-05-02, 2024_05_02 or 20240502.
+var namedDate = regexp.MustCompile(`^(\d{4})[-_.]?(\d{2})[-_.]?(\d{2})(?:\D|$)`)
+
+// DateFromName returns the date a file name starts with.
+func DateFromName(name string) (time.Time, bool) {
+	m := namedDate.FindStringSubmatch(name)
+	if m == nil {
+		return time.Time{}, false
+	}
+	t, err := time.ParseInLocation("2006-01-02", m[1]+"-"+m[2]+"-"+m[3], time.Local)
+	return t, err == nil
+}
+
+// EntryDate returns the document date of a file entry in dir and where it
+// came from: the date prefix of the file name ("name") or, for PDFs without
+// one, the CreationDate of the document ("pdf").
+func EntryDate(dir string, e CatEntry) (time.Time, string) {
+	if t, ok := DateFromName(e.Name); ok {
+		return t, "name"
+	}
+	if e.Type == "file" && isPDF(e.Name) {
+		if r, err := pdf.Open(EntryPath(dir, e)); err == nil {
+			if t := r.Info().CreationDate; !t.IsZero() {
+				return t, "pdf"
+			}
+		}
+	}
+	return time.Time{}, ""
+}
+
+func isPDF(name string) bool {
+	return strings.EqualFold(filepath.Ext(name), ".pdf")
+}
+
+// pdfSummary describes a PDF for the long catalog view, e.g.
+// "3 pages, title: Steuerbescheid 2023, created 2024-05-02".
+func pdfSummary(path string) string {
+	r, err := pdf.Open(path)
+	if err != nil {
+		return ""
+	}
+	info := r.Info()
+	parts := []string{fmt.Sprintf("%d pages", r.NumPages())}
+	if info.Title != "" {
+		parts = append(parts, "title: "+info.Title)
+	}
+	if !info.CreationDate.IsZero() {
+		parts = append(parts, "created "+info.CreationDate.Format("2006-01-02"))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// CmdPDFKeywords adds the Keywords of PDF entries as tags:
+// pdfkw [<num>] [-n]. Without a number all PDF entries are processed; -n only
+// shows what would be added.
+func CmdPDFKeywords(w io.Writer, args []string) error {
+	Lock()
+	defer Unlock()
+	entries, err := LoadCatalog()
+	if err != nil {
+		return err
+	}
+	dryRun := false
+	only := -1
+	for _, a := range args {
+		if a == "-n" {
+			dryRun = true
+			continue
+		}
+		if only, err = EntryIndex(a, len(entries)); err != nil {
+			return err
+		}
+	}
+	cwd, _ := os.Getwd()
+	changed := 0
+	for i := range entries {
+		e := &entries[i]
+		if (only >= 0 && i != only) || e.Type != "file" || !isPDF(e.Name) {
+			continue
+		}
+		r, err := pdf.Open(EntryPath(cwd, *e))
+		if err != nil {
+			if only >= 0 {
+				return fmt.Errorf("%s: %w", e.Name, err)
+			}
+			continue
+		}
+		var added []string
+		for _, kw := range r.Info().KeywordList() {
+			kw = fieldValue(kw)
+			if kw != "" && !strings.HasPrefix(kw, "@") && !containsTag(e.Tags, kw) {
+				e.Tags = append(e.Tags, kw)
+				added = append(added, kw)
+			}
+		}
+		if len(added) > 0 {
+			changed++
+			fmt.Fprintf(w, "%d %s: +%s\n", i+1, e.Name, strings.Join(added, ", +"))
+		}
+	}
+	if changed == 0 {
+		fmt.Fprintln(w, "no new keywords found")
+		return nil
+	}
+	if dryRun {
+		fmt.Fprintf(w, "(dry run, %d entries would change)\n", changed)
+		return nil
+	}
+	if err := SaveCatalog(entries); err != nil {
+		return fmt.Errorf("saving catalog: %w", err)
+	}
+	fmt.Fprintf(w, "keywords added to %d entries\n", changed)
+	return nil
+}
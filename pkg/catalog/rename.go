@@ -0,0 +1,334 @@
+package catalog
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Renaming moves files on disk and updates their entries, so tags, titles
+// and notes stay attached. Batches are applied through temporary names,
+// which makes swaps (a->b, b->a) and longer cycles safe.
+
+// CheckName reports whether name can be used for a file entry. Besides
+// path separators it rejects the characters the .cat format gives a
+// meaning: '*' separates fields and ':' marks a URL entry.
+func CheckName(name string) error {
+	switch {
+	case strings.TrimSpace(name) == "":
+		return fmt.Errorf("empty name")
+	case strings.HasPrefix(name, "."):
+		return fmt.Errorf("%q would be hidden", name)
+	case strings.ContainsAny(name, "*:\n\r"), strings.ContainsRune(name, filepath.Separator), strings.ContainsRune(name, '/'):
+		return fmt.Errorf("%q contains '*', ':', '/' or a line break", name)
+	}
+	return nil
+}
+
+// RenameEntries renames the files of entries in dir according to plan
+// (entry index -> new name) and updates the entries. It refuses to
+// overwrite files that are not renamed themselves and undoes completed
+// renames on failure. Entries are only changed when all renames succeed;
+// saving the catalog is left to the caller.
+func RenameEntries(dir string, entries []CatEntry, plan map[int]string) error {
+	targets := map[string]int{}
+	sources := map[string]bool{}
+	for i, name := range plan {
+		if i < 0 || i >= len(entries) || entries[i].Type != "file" {
+			return fmt.Errorf("entry %d is not a file", i+1)
+		}
+		if err := CheckName(name); err != nil {
+			return err
+		}
+		if j, dup := targets[name]; dup {
+			return fmt.Errorf("entries %d and %d would both be named %s", j+1, i+1, name)
+		}
+		targets[name] = i
+		sources[entries[i].Name] = true
+	}
+	for i, e := range entries {
+		if j, ok := targets[e.Name]; ok && j != i {
+			if _, moving := plan[i]; !moving {
+				return fmt.Errorf("%s is already in the catalog (entry %d)", e.Name, i+1)
+			}
+		}
+	}
+	for name := range targets {
+		if sources[name] {
+			continue
+		}
+		if _, err := os.Lstat(filepath.Join(dir, name)); err == nil {
+			return fmt.Errorf("%s already exists", name)
+		}
+	}
+
+	order := make([]int, 0, len(plan))
+	for i := range plan {
+		if plan[i] != entries[i].Name {
+			order = append(order, i)
+		}
+	}
+	sort.Ints(order)
+	type step struct{ from, to string }
+	var done []step
+	undo := func() {
+		for k := len(done) - 1; k >= 0; k-- {
+			os.Rename(done[k].to, done[k].from)
+		}
+	}
+	move := func(from, to string) error {
+		if err := os.Rename(from, to); err != nil {
+			undo()
+			return err
+		}
+		done = append(done, step{from, to})
+		return nil
+	}
+	// first move every file out of the way, then into place
+	temp := map[int]string{}
+	for _, i := range order {
+		tmp := filepath.Join(dir, fmt.Sprintf(".filemac-mv-%d-%d", os.Getpid(), i))
+		if err := move(filepath.Join(dir, entries[i].Name), tmp); err != nil {
+			return err
+		}
+		temp[i] = tmp
+	}
+	for _, i := range order {
+		dst := filepath.Join(dir, plan[i])
+		if _, err := os.Lstat(dst); err == nil {
+			undo()
+			return fmt.Errorf("%s already exists", plan[i])
+		}
+		if err := move(temp[i], dst); err != nil {
+			return err
+		}
+	}
+	for _, i := range order {
+		entries[i].Name = plan[i]
+	}
+	return nil
+}
+
+// describeCycles explains which renames of a batch depend on each other.
+func describeCycles(entries []CatEntry, plan map[int]string) []string {
+	byName := map[string]int{}
+	for i := range plan {
+		byName[entries[i].Name] = i
+	}
+	var out []string
+	seen := map[int]bool{}
+	for _, start := range sortedKeys(plan) {
+		if seen[start] {
+			continue
+		}
+		chain := []int{}
+		for i, ok := start, true; ok && !seen[i]; i, ok = byName[plan[i]] {
+			seen[i] = true
+			chain = append(chain, i)
+		}
+		// the chain ends in a cycle if its last target is one of its names
+		last := plan[chain[len(chain)-1]]
+		for k, i := range chain {
+			if entries[i].Name != last || k == len(chain)-1 {
+				continue
+			}
+			cycle := chain[k:]
+			kind := "cycle"
+			if len(cycle) == 2 {
+				kind = "swap"
+			}
+			var names []string
+			for _, i := range cycle {
+				names = append(names, entries[i].Name)
+			}
+			out = append(out, fmt.Sprintf("%s: %s -> %s", kind, strings.Join(names, " -> "), last))
+		}
+	}
+	return out
+}
+
+func sortedKeys(plan map[int]string) []int {
+	keys := make([]int, 0, len(plan))
+	for i := range plan {
+		keys = append(keys, i)
+	}
+	sort.Ints(keys)
+	return keys
+}
+
+// CmdMove renames the file of entry num: mv <num> <newname>. A new name
+// without extension keeps the old one.
+func CmdMove(w io.Writer, num string, newname []string) error {
+	Lock()
+	defer Unlock()
+	entries, err := LoadCatalog()
+	if err != nil {
+		return err
+	}
+	i, err := EntryIndex(num, len(entries))
+	if err != nil {
+		return err
+	}
+	name := strings.TrimSpace(strings.Join(newname, " "))
+	if name == "" {
+		return fmt.Errorf("usage: mv <num> <newname>")
+	}
+	if filepath.Ext(name) == "" {
+		name += filepath.Ext(entries[i].Name)
+	}
+	old := entries[i].Name
+	cwd, _ := os.Getwd()
+	if err := RenameEntries(cwd, entries, map[int]string{i: name}); err != nil {
+		return fmt.Errorf("mv: %w", err)
+	}
+	if err := SaveCatalog(entries); err != nil {
+		os.Rename(filepath.Join(cwd, name), filepath.Join(cwd, old))
+		return fmt.Errorf("saving catalog: %w", err)
+	}
+	fmt.Fprintf(w, "renamed %s -> %s\n", old, name)
+	return nil
+}
+
+// CmdRenameEditor opens the names of all file entries in $EDITOR for batch
+// renaming (ren). Lines keep their entry number; deleting a line leaves
+// that entry alone.
+func CmdRenameEditor(w io.Writer) error {
+	entries, err := LoadCatalog()
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp("", "filemac-ren-*.txt")
+	if err != nil {
+		return fmt.Errorf("ren: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	list := bufio.NewWriter(tmp)
+	for i, e := range entries {
+		if e.Type == "file" {
+			fmt.Fprintf(list, "%d\t%s\n", i+1, e.Name)
+		}
+	}
+	list.Flush()
+	tmp.Close()
+
+	if err := runEditor(tmp.Name()); err != nil {
+		return fmt.Errorf("ren: %w", err)
+	}
+	data, err := os.ReadFile(tmp.Name())
+	if err != nil {
+		return fmt.Errorf("ren: %w", err)
+	}
+	// the numbers refer to the catalog as it was when the editor opened
+	Lock()
+	defer Unlock()
+	current, err := LoadCatalog()
+	if err != nil {
+		return err
+	}
+	if !sameNames(entries, current) {
+		return fmt.Errorf("ren: the catalog changed while editing, run ren again")
+	}
+	entries = current
+	plan, err := ParseRenameList(string(data), entries)
+	if err != nil {
+		return fmt.Errorf("ren: %w", err)
+	}
+	if len(plan) == 0 {
+		fmt.Fprintln(w, "nothing renamed")
+		return nil
+	}
+	for _, c := range describeCycles(entries, plan) {
+		fmt.Fprintln(w, c)
+	}
+	old := make([]CatEntry, len(entries))
+	copy(old, entries)
+	cwd, _ := os.Getwd()
+	if err := RenameEntries(cwd, entries, plan); err != nil {
+		return fmt.Errorf("ren: %w", err)
+	}
+	if err := SaveCatalog(entries); err != nil {
+		revert := map[int]string{}
+		for i := range plan {
+			revert[i] = old[i].Name
+		}
+		if rerr := RenameEntries(cwd, entries, revert); rerr != nil {
+			return fmt.Errorf("saving catalog: %w (could not undo renames: %v)", err, rerr)
+		}
+		return fmt.Errorf("saving catalog: %w", err)
+	}
+	for _, i := range sortedKeys(plan) {
+		fmt.Fprintf(w, "renamed %s -> %s\n", old[i].Name, plan[i])
+	}
+	return nil
+}
+
+// sameNames reports whether a and b list the same names in the same order.
+func sameNames(a, b []CatEntry) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].Name != b[i].Name {
+			return false
+		}
+	}
+	return true
+}
+
+// ParseRenameList reads "num<TAB>name" lines as written by ren and returns
+// the entries whose name changed. Every resulting name, changed or not,
+// must be unique.
+func ParseRenameList(text string, entries []CatEntry) (map[int]string, error) {
+	plan := map[int]string{}
+	listed := map[int]bool{}
+	lineOf := map[string]int{}
+	for k, line := range strings.Split(text, "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		num, name, ok := strings.Cut(line, "\t")
+		n, err := strconv.Atoi(strings.TrimSpace(num))
+		if !ok || err != nil || n < 1 || n > len(entries) {
+			return nil, fmt.Errorf("line %d: expected <num><TAB><name>", k+1)
+		}
+		if listed[n-1] {
+			return nil, fmt.Errorf("line %d: entry %d listed twice", k+1, n)
+		}
+		listed[n-1] = true
+		name = strings.TrimSpace(name)
+		if first, dup := lineOf[name]; dup {
+			return nil, fmt.Errorf("line %d: %s is also the name on line %d", k+1, name, first)
+		}
+		lineOf[name] = k + 1
+		if name != entries[n-1].Name {
+			plan[n-1] = name
+		}
+	}
+	for i, e := range entries {
+		if line, ok := lineOf[e.Name]; ok && !listed[i] {
+			return nil, fmt.Errorf("line %d: %s is already the name of entry %d", line, e.Name, i+1)
+		}
+	}
+	return plan, nil
+}
+
+// runEditor edits file with $VISUAL, $EDITOR or vi.
+func runEditor(file string) error {
+	editor := os.Getenv("VISUAL")
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+	if editor == "" {
+		editor = "vi"
+	}
+	args := strings.Fields(editor)
+	cmd := exec.Command(args[0], append(args[1:], file)...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	return cmd.Run()
+}
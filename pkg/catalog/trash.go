@@ -0,0 +1,543 @@
+package catalog
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tenzokai/filemac/pkg/config"
+	"github.com/tenzokai/filemac/pkg/table"
+)
+
+// TrashDirname is the folder trash next to .cat. It holds deleted files and
+// the tombstones file, which remembers the catalog line of every deleted or
+// vanished entry so it can be restored with its tags.
+var TrashDirname = ".cattrash"
+
+const tombstoneFile = "tombstones"
+
+// Tombstone records a removed catalog entry.
+type Tombstone struct {
+	Deleted time.Time
+	Stored  string // where the file was put; empty if it vanished outside filemac
+	Entry   CatEntry
+}
+
+// LoadTombstones reads the tombstones of the catalog folder dir, oldest
+// first.
+func LoadTombstones(dir string) ([]Tombstone, error) {
+	f, err := os.Open(filepath.Join(dir, TrashDirname, tombstoneFile))
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var out []Tombstone
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		parts := strings.SplitN(scanner.Text(), "\t", 3)
+		if len(parts) != 3 {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, parts[0])
+		if err != nil {
+			continue
+		}
+		stored := parts[1]
+		if stored == "-" {
+			stored = ""
+		}
+		out = append(out, Tombstone{Deleted: t, Stored: stored, Entry: ParseCatalogLine(parts[2])})
+	}
+	return out, scanner.Err()
+}
+
+// SaveTombstones replaces the tombstones of dir.
+func SaveTombstones(dir string, ts []Tombstone) error {
+	trash := filepath.Join(dir, TrashDirname)
+	if err := os.MkdirAll(trash, 0755); err != nil {
+		return err
+	}
+	var b strings.Builder
+	for _, t := range ts {
+		stored := t.Stored
+		if stored == "" {
+			stored = "-"
+		}
+		fmt.Fprintf(&b, "%s\t%s\t%s\n", t.Deleted.Format(time.RFC3339), stored, FormatCatalogLine(t.Entry))
+	}
+	tmp, err := os.CreateTemp(trash, tombstoneFile+".tmp*")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.WriteString(b.String())
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), filepath.Join(trash, tombstoneFile))
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+	return err
+}
+
+// Vanished returns tombstones for entries whose files vanished without
+// filemac's help.
+func Vanished(entries []CatEntry) []Tombstone {
+	now := time.Now().Truncate(time.Second)
+	var ts []Tombstone
+	for _, e := range entries {
+		ts = append(ts, Tombstone{Deleted: now, Entry: e})
+	}
+	return ts
+}
+
+// appendTombstones adds ts to the tombstones of dir.
+func appendTombstones(dir string, ts []Tombstone) error {
+	if len(ts) == 0 {
+		return nil
+	}
+	old, err := LoadTombstones(dir)
+	if err != nil {
+		return err
+	}
+	return SaveTombstones(dir, append(old, ts...))
+}
+
+// SaveRemoval saves after as the catalog of src and then records the
+// tombstones of the removed entries. The catalog goes first, so a failed
+// save leaves no tombstones for entries that are still listed; if the
+// tombstones cannot be saved, before is saved back so no tags are lost and
+// the removal can simply be repeated.
+func SaveRemoval(src Source, before, after []CatEntry, removed []Tombstone) error {
+	if err := SaveCatalogAt(src.File, after); err != nil {
+		return fmt.Errorf("saving catalog: %w", err)
+	}
+	if err := appendTombstones(src.Dir, removed); err != nil {
+		if rerr := SaveCatalogAt(src.File, before); rerr != nil {
+			return fmt.Errorf("trash: %w (could not put the entries back: %v)", err, rerr)
+		}
+		return fmt.Errorf("trash: %w", err)
+	}
+	return nil
+}
+
+// TrashEntries moves the files of the entries at indices into the trash
+// and returns the remaining entries and the tombstones of the removed
+// ones. URL entries are only tombstoned. With system set the freedesktop
+// trash is used where available. Nothing is saved: see SaveRemoval, and
+// UntrashFiles to move the files back if that fails.
+func TrashEntries(dir string, entries []CatEntry, indices []int, system bool) ([]CatEntry, []Tombstone, error) {
+	remove := map[int]bool{}
+	var ts []Tombstone
+	var failed error
+	for _, i := range indices {
+		e := entries[i]
+		t := Tombstone{Deleted: time.Now().Truncate(time.Second), Entry: e}
+		if e.Type == "file" {
+			var err error
+			t.Stored, err = trashFile(filepath.Join(dir, e.Name), filepath.Join(dir, TrashDirname), system)
+			if err != nil && !os.IsNotExist(err) {
+				failed = fmt.Errorf("%s: %v", e.Name, err)
+				continue
+			}
+		}
+		ts = append(ts, t)
+		remove[i] = true
+	}
+	var kept []CatEntry
+	for i, e := range entries {
+		if !remove[i] {
+			kept = append(kept, e)
+		}
+	}
+	return kept, ts, failed
+}
+
+// UntrashFiles moves the files of ts back into dir, undoing TrashEntries.
+func UntrashFiles(dir string, ts []Tombstone) error {
+	var failed error
+	for _, t := range ts {
+		if t.Stored == "" {
+			continue
+		}
+		if err := os.Rename(t.Stored, filepath.Join(dir, t.Entry.Name)); err != nil {
+			failed = err
+			continue
+		}
+		removeTrashInfo(t.Stored)
+	}
+	return failed
+}
+
+// trashFile moves path into the freedesktop trash or into trash and
+// returns its new location.
+func trashFile(path, trash string, system bool) (string, error) {
+	if _, err := os.Lstat(path); err != nil {
+		return "", err
+	}
+	if system && runtime.GOOS != "windows" && runtime.GOOS != "darwin" {
+		if stored, err := systemTrash(path); err == nil {
+			return stored, nil
+		}
+		// e.g. another file system: use the folder trash instead
+	}
+	if err := os.MkdirAll(trash, 0755); err != nil {
+		return "", err
+	}
+	base := time.Now().Format("20060102-150405") + "_" + filepath.Base(path)
+	stored := filepath.Join(trash, base)
+	for n := 2; exists(stored); n++ {
+		stored = filepath.Join(trash, fmt.Sprintf("%d_%s", n, base))
+	}
+	return stored, os.Rename(path, stored)
+}
+
+// systemTrash moves path to the home trash of the freedesktop.org trash
+// specification.
+func systemTrash(path string) (string, error) {
+	data := os.Getenv("XDG_DATA_HOME")
+	if data == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		data = filepath.Join(home, ".local", "share")
+	}
+	files, info := filepath.Join(data, "Trash", "files"), filepath.Join(data, "Trash", "info")
+	for _, d := range []string{files, info} {
+		if err := os.MkdirAll(d, 0700); err != nil {
+			return "", err
+		}
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	name := filepath.Base(path)
+	ext := filepath.Ext(name)
+	for n := 1; ; n++ {
+		candidate := name
+		if n > 1 {
+			candidate = fmt.Sprintf("%s.%d%s", strings.TrimSuffix(name, ext), n, ext)
+		}
+		infoFile := filepath.Join(info, candidate+".trashinfo")
+		f, err := os.OpenFile(infoFile, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+		if os.IsExist(err) {
+			continue
+		} else if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(f, "[Trash Info]\nPath=%s\nDeletionDate=%s\n",
+			(&url.URL{Path: abs}).EscapedPath(), time.Now().Format("2006-01-02T15:04:05"))
+		f.Close()
+		stored := filepath.Join(files, candidate)
+		if err := os.Rename(abs, stored); err != nil {
+			os.Remove(infoFile)
+			return "", err
+		}
+		return stored, nil
+	}
+}
+
+// removeTrashInfo deletes the .trashinfo file of a file restored from or
+// purged in the freedesktop trash.
+func removeTrashInfo(stored string) {
+	files := filepath.Dir(stored)
+	if filepath.Base(files) == "files" {
+		os.Remove(filepath.Join(filepath.Dir(files), "info", filepath.Base(stored)+".trashinfo"))
+	}
+}
+
+func exists(path string) bool {
+	_, err := os.Lstat(path)
+	return err == nil
+}
+
+// RestoreTombstones puts the files of the tombstones at indices back into
+// dir and returns the catalog entries with the restored tags and the
+// tombstones that are left. An entry that is in the catalog again (e.g.
+// re-added by init) gets the old tags merged in. Vanished files must be
+// back in dir before they can be restored.
+//
+// Nothing is saved: the caller saves the catalog first and only then the
+// remaining tombstones with SaveTombstones, so tags are never lost. A file
+// that was already moved back by an earlier, failed restore is accepted.
+func RestoreTombstones(dir string, entries []CatEntry, indices []int) ([]CatEntry, []Tombstone, error) {
+	ts, err := LoadTombstones(dir)
+	if err != nil {
+		return entries, nil, err
+	}
+	done := map[int]bool{}
+	var failed error
+	for _, i := range indices {
+		if i < 0 || i >= len(ts) {
+			return entries, ts, fmt.Errorf("no tombstone %d", i+1)
+		}
+		t := ts[i]
+		e := t.Entry
+		target := filepath.Join(dir, e.Name)
+		if e.Type == "file" {
+			switch {
+			case t.Stored != "" && exists(target) && !exists(t.Stored):
+				// restored before, but the catalog could not be saved
+			case t.Stored != "" && exists(target):
+				failed = fmt.Errorf("%s exists, rename it first", e.Name)
+				continue
+			case t.Stored != "":
+				if err := os.Rename(t.Stored, target); err != nil {
+					failed = err
+					continue
+				}
+				removeTrashInfo(t.Stored)
+			case !exists(target):
+				failed = fmt.Errorf("%s vanished, put it back into %s first", e.Name, dir)
+				continue
+			}
+		}
+		merged := false
+		for k := range entries {
+			if entries[k].Name == e.Name {
+				for _, tag := range e.Tags {
+					if !containsTag(entries[k].Tags, tag) {
+						entries[k].Tags = append(entries[k].Tags, tag)
+					}
+				}
+				if entries[k].Title == "" {
+					entries[k].Title = e.Title
+				}
+				if entries[k].Note == "" {
+					entries[k].Note = e.Note
+				}
+				merged = true
+			}
+		}
+		if !merged {
+			entries = append(entries, e)
+		}
+		done[i] = true
+	}
+	var left []Tombstone
+	for i, t := range ts {
+		if !done[i] {
+			left = append(left, t)
+		}
+	}
+	return entries, left, failed
+}
+
+// PurgeTrash deletes trashed files and tombstones older than age and
+// returns how many tombstones were removed.
+func PurgeTrash(dir string, age time.Duration) (int, error) {
+	ts, err := LoadTombstones(dir)
+	if err != nil {
+		return 0, err
+	}
+	cutoff := time.Now().Add(-age)
+	var left []Tombstone
+	for _, t := range ts {
+		if !t.Deleted.Before(cutoff) {
+			left = append(left, t)
+			continue
+		}
+		if t.Stored != "" {
+			if err := os.Remove(t.Stored); err != nil && !os.IsNotExist(err) {
+				left = append(left, t)
+				continue
+			}
+			removeTrashInfo(t.Stored)
+		}
+	}
+	return len(ts) - len(left), SaveTombstones(dir, left)
+}
+
+// ParseAge parses ages like 90d, 2w or 12h (and Go durations).
+func ParseAge(s string) (time.Duration, error) {
+	unit := map[byte]time.Duration{'d': 24 * time.Hour, 'w': 7 * 24 * time.Hour}
+	if len(s) > 1 {
+		if u, ok := unit[s[len(s)-1]]; ok {
+			n, err := strconv.Atoi(s[:len(s)-1])
+			if err == nil && n >= 0 {
+				return time.Duration(n) * u, nil
+			}
+		}
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid age %q, use e.g. 90d, 2w or 12h", s)
+	}
+	return d, nil
+}
+
+// ParseSelector turns "3", "3-5" and "1,4" (also several arguments) into
+// sorted entry indices for a list of n entries.
+func ParseSelector(args []string, n int) ([]int, error) {
+	set := map[int]bool{}
+	for _, arg := range args {
+		for _, part := range strings.Split(arg, ",") {
+			if part == "" {
+				continue
+			}
+			lo, hi, isRange := strings.Cut(part, "-")
+			a, err1 := strconv.Atoi(lo)
+			b := a
+			var err2 error
+			if isRange {
+				b, err2 = strconv.Atoi(hi)
+			}
+			if err1 != nil || err2 != nil || a < 1 || b > n || a > b {
+				return nil, fmt.Errorf("%w: %q (entries 1-%d)", ErrBadIndex, part, n)
+			}
+			for i := a; i <= b; i++ {
+				set[i-1] = true
+			}
+		}
+	}
+	if len(set) == 0 {
+		return nil, fmt.Errorf("no entries selected")
+	}
+	out := make([]int, 0, len(set))
+	for i := range set {
+		out = append(out, i)
+	}
+	sort.Ints(out)
+	return out, nil
+}
+
+// CmdRemove moves entries to the trash: rm <selector>, e.g. rm 3, rm 3-5,
+// rm 1,4.
+func CmdRemove(w io.Writer, args []string) error {
+	Lock()
+	defer Unlock()
+	entries, err := LoadCatalog()
+	if err != nil {
+		return err
+	}
+	if len(args) == 0 {
+		return fmt.Errorf("usage: rm <num|from-to|list>")
+	}
+	indices, err := ParseSelector(args, len(entries))
+	if err != nil {
+		return err
+	}
+	cfg, _ := config.Load()
+	cwd, _ := os.Getwd()
+	// an unreadable tombstones file stops rm before any file is moved
+	if _, err := LoadTombstones(cwd); err != nil {
+		return fmt.Errorf("trash: %w", err)
+	}
+	kept, removed, failed := TrashEntries(cwd, entries, indices, cfg != nil && cfg.Trash.System)
+	if failed != nil {
+		failed = fmt.Errorf("rm: %w", failed)
+	}
+	if len(removed) == 0 {
+		return failed
+	}
+	if err := SaveRemoval(Source{Dir: cwd, File: CatalogFilename}, entries, kept, removed); err != nil {
+		if uerr := UntrashFiles(cwd, removed); uerr != nil {
+			return fmt.Errorf("%w (could not move files back from the trash: %v)", err, uerr)
+		}
+		return err
+	}
+	fmt.Fprintf(w, "%d entries moved to trash (see 'trash ls', 'restore')\n", len(removed))
+	return failed
+}
+
+// CmdTrash manages the trash of the current folder: trash ls,
+// trash purge --older <age>.
+func CmdTrash(w io.Writer, args []string) error {
+	cwd, _ := os.Getwd()
+	switch {
+	case len(args) == 0 || args[0] == "ls":
+		ts, err := LoadTombstones(cwd)
+		if err != nil {
+			return fmt.Errorf("trash: %w", err)
+		}
+		if len(ts) == 0 {
+			fmt.Fprintln(w, "(trash is empty)")
+			return nil
+		}
+		tbl := table.New(
+			table.Column{Title: "num", Min: 6},
+			table.Column{Title: "deleted"},
+			table.Column{Title: "file", Min: 8},
+			table.Column{Title: "name", Min: 16, Middle: true},
+			table.Column{Title: "tags", Min: 10, Wrap: true},
+		)
+		for i, t := range ts {
+			state := "trash"
+			if t.Entry.Type == "url" {
+				state = "url"
+			} else if t.Stored == "" {
+				state = "vanished"
+			}
+			tbl.Add(strconv.Itoa(i+1), t.Deleted.Format("2006-01-02"), state, t.Entry.Name, strings.Join(t.Entry.Tags, ", "))
+		}
+		tbl.Render(w)
+	case args[0] == "purge" && len(args) == 3 && args[1] == "--older":
+		age, err := ParseAge(args[2])
+		if err != nil {
+			return err
+		}
+		Lock()
+		n, err := PurgeTrash(cwd, age)
+		Unlock()
+		if err != nil {
+			return fmt.Errorf("trash: %w", err)
+		}
+		fmt.Fprintf(w, "purged %d entries\n", n)
+	default:
+		return fmt.Errorf("usage: trash ls | trash purge --older <age, e.g. 90d>")
+	}
+	return nil
+}
+
+// CmdRestore brings entries back from the trash: restore <selector>, using
+// the numbers of 'trash ls'.
+func CmdRestore(w io.Writer, args []string) error {
+	Lock()
+	defer Unlock()
+	cwd, _ := os.Getwd()
+	ts, err := LoadTombstones(cwd)
+	if err != nil {
+		return fmt.Errorf("trash: %w", err)
+	}
+	if len(args) == 0 {
+		return fmt.Errorf("usage: restore <num|from-to|list> (numbers from 'trash ls')")
+	}
+	indices, err := ParseSelector(args, len(ts))
+	if err != nil {
+		return err
+	}
+	entries, err := LoadCatalog()
+	if err != nil {
+		return err
+	}
+	entries, left, failed := RestoreTombstones(cwd, entries, indices)
+	if failed != nil {
+		failed = fmt.Errorf("restore: %w", failed)
+	}
+	if len(left) == len(ts) {
+		return failed
+	}
+	// the catalog first: if saving it fails the tombstones keep the tags
+	if err := SaveCatalog(entries); err != nil {
+		return fmt.Errorf("saving catalog: %w", err)
+	}
+	if err := SaveTombstones(cwd, left); err != nil {
+		return fmt.Errorf("trash: %w", err)
+	}
+	fmt.Fprintf(w, "restored %d entries\n", len(ts)-len(left))
+	return failed
+}
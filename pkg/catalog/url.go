@@ -0,0 +1,114 @@
+package catalog
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+)
+
+// trackingParams are query parameters that only identify the referrer and
+// are dropped when normalizing URLs.
+var trackingParams = map[string]bool{
+	"fbclid": true, "gclid": true, "dclid": true, "msclkid": true, "yclid": true,
+	"mc_cid": true, "mc_eid": true, "igshid": true, "ref_src": true,
+	"_hsenc": true, "_hsmi": true,
+}
+
+// NormalizeURL validates raw and returns its canonical form: http(s) only
+// (https is assumed when the scheme is missing), lower-case host, no default
+// port, no trailing slash, no tracking parameters, sorted query.
+func NormalizeURL(raw string) (string, error) {
+	raw = strings.TrimSpace(raw)
+	if !strings.Contains(raw, "://") {
+		raw = "https://" + raw
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "", fmt.Errorf("invalid URL: %v", err)
+	}
+	u.Scheme = strings.ToLower(u.Scheme)
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return "", fmt.Errorf("unsupported URL scheme %q", u.Scheme)
+	}
+	host := strings.ToLower(u.Hostname())
+	if host == "" || strings.ContainsAny(host, " *") {
+		return "", fmt.Errorf("invalid URL host in %q", raw)
+	}
+	port := u.Port()
+	if (u.Scheme == "http" && port == "80") || (u.Scheme == "https" && port == "443") {
+		port = ""
+	}
+	u.Host = host
+	if port != "" {
+		u.Host = host + ":" + port
+	}
+	u.Path = strings.TrimRight(u.Path, "/")
+	u.RawPath = ""
+	if u.RawQuery != "" {
+		q := u.Query()
+		for key := range q {
+			if strings.HasPrefix(strings.ToLower(key), "utm_") || trackingParams[strings.ToLower(key)] {
+				q.Del(key)
+			}
+		}
+		u.RawQuery = q.Encode()
+	}
+	if u.Fragment == "" {
+		u.RawFragment = ""
+	}
+	// '*' separates fields in .cat lines
+	return strings.ReplaceAll(u.String(), "*", "%2A"), nil
+}
+
+// CmdAddURL adds a URL entry: au <url> [tag...] [-t title...].
+func CmdAddURL(w io.Writer, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: au <url> [tag...] [-t title...]")
+	}
+	norm, err := NormalizeURL(args[0])
+	if err != nil {
+		return err
+	}
+	var tags []string
+	title := ""
+	for i, a := range args[1:] {
+		if a == "-t" {
+			title = strings.Join(args[i+2:], " ")
+			break
+		}
+		a = strings.TrimSpace(a)
+		if a != "" && !containsTag(tags, a) {
+			tags = append(tags, a)
+		}
+	}
+	Lock()
+	defer Unlock()
+	entries, err := LoadCatalog()
+	if err != nil {
+		return err
+	}
+	for i, e := range entries {
+		if e.Type != "url" {
+			continue
+		}
+		if existing, err := NormalizeURL(e.Name); err == nil && existing == norm {
+			return fmt.Errorf("URL already in catalog as entry %d", i+1)
+		}
+	}
+	entries = append(entries, CatEntry{Name: norm, Type: "url", Tags: tags, Title: fieldValue(title)})
+	if err := SaveCatalog(entries); err != nil {
+		return fmt.Errorf("saving catalog: %w", err)
+	}
+	fmt.Fprintf(w, "added %s as entry %d\n", norm, len(entries))
+	return nil
+}
+
+func containsTag(tags []string, tag string) bool {
+	for _, t := range tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,152 @@
+package catalog
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// SortKeys are the orders vc -sort understands.
+var SortKeys = []string{"name", "date", "ext", "tags", "mtime"}
+
+// ViewOptions select and order the entries shown by vc.
+type ViewOptions struct {
+	New     bool      // only entries without tags
+	Long    bool      // show titles, notes and PDF details
+	Missing bool      // only file entries whose file is gone
+	Tags    []string  // only entries with all of these tags
+	Ext     string    // only files with this extension (lower case, no dot)
+	Since   time.Time // only entries dated on or after this
+	Sort    string    // one of SortKeys, empty keeps catalog order
+}
+
+// ParseViewArgs reads the vc options -new, -l, -missing, -tag <tag>,
+// -ext <ext>, -since <age|YYYY-MM-DD> and -sort <key>.
+func ParseViewArgs(args []string) (ViewOptions, error) {
+	var o ViewOptions
+	for i := 0; i < len(args); i++ {
+		a := args[i]
+		switch a {
+		case "-new":
+			o.New = true
+			continue
+		case "-l":
+			o.Long = true
+			continue
+		case "-missing":
+			o.Missing = true
+			continue
+		case "-tag", "-ext", "-since", "-sort":
+		default:
+			return o, fmt.Errorf("unknown option %s", a)
+		}
+		if i+1 >= len(args) {
+			return o, fmt.Errorf("%s needs a value", a)
+		}
+		i++
+		v := args[i]
+		switch a {
+		case "-tag":
+			o.Tags = append(o.Tags, v)
+		case "-ext":
+			o.Ext = strings.ToLower(strings.TrimPrefix(v, "."))
+		case "-since":
+			if t, err := time.ParseInLocation("2006-01-02", v, time.Local); err == nil {
+				o.Since = t
+				break
+			}
+			age, err := ParseAge(v)
+			if err != nil {
+				return o, fmt.Errorf("-since: %v", err)
+			}
+			o.Since = time.Now().Add(-age)
+		case "-sort":
+			if !containsTag(SortKeys, v) {
+				return o, fmt.Errorf("-sort must be one of %s", strings.Join(SortKeys, ", "))
+			}
+			o.Sort = v
+		}
+	}
+	return o, nil
+}
+
+// SelectEntries returns the indices of the entries of the catalog in dir
+// that match o, in the order o asks for.
+func SelectEntries(dir string, entries []CatEntry, o ViewOptions) []int {
+	dates := map[int]time.Time{}
+	date := func(i int) time.Time {
+		if t, ok := dates[i]; ok {
+			return t
+		}
+		t, _ := EntryDate(dir, entries[i])
+		if t.IsZero() {
+			t = modTime(dir, entries[i])
+		}
+		dates[i] = t
+		return t
+	}
+
+	var out []int
+	for i, e := range entries {
+		if o.New && len(e.Tags) > 0 {
+			continue
+		}
+		if o.Missing && (e.Type != "file" || exists(EntryPath(dir, e))) {
+			continue
+		}
+		if o.Ext != "" && (e.Type != "file" || strings.ToLower(strings.TrimPrefix(filepath.Ext(e.Name), ".")) != o.Ext) {
+			continue
+		}
+		if !hasAllTags(e, o.Tags) {
+			continue
+		}
+		if !o.Since.IsZero() && date(i).Before(o.Since) {
+			continue
+		}
+		out = append(out, i)
+	}
+
+	var less func(a, b int) bool
+	switch o.Sort {
+	case "name":
+		less = func(a, b int) bool { return strings.ToLower(entries[a].Name) < strings.ToLower(entries[b].Name) }
+	case "ext":
+		less = func(a, b int) bool {
+			return strings.ToLower(filepath.Ext(entries[a].Name)) < strings.ToLower(filepath.Ext(entries[b].Name))
+		}
+	case "tags":
+		less = func(a, b int) bool { return strings.Join(entries[a].Tags, ",") < strings.Join(entries[b].Tags, ",") }
+	case "date":
+		less = func(a, b int) bool { return date(a).Before(date(b)) }
+	case "mtime":
+		less = func(a, b int) bool { return modTime(dir, entries[a]).Before(modTime(dir, entries[b])) }
+	}
+	if less != nil {
+		sort.SliceStable(out, func(x, y int) bool { return less(out[x], out[y]) })
+	}
+	return out
+}
+
+func hasAllTags(e CatEntry, tags []string) bool {
+	for _, t := range tags {
+		if !containsTag(e.Tags, t) {
+			return false
+		}
+	}
+	return true
+}
+
+// modTime returns the modification time of a file entry, or the zero time.
+func modTime(dir string, e CatEntry) time.Time {
+	if e.Type != "file" {
+		return time.Time{}
+	}
+	fi, err := os.Stat(EntryPath(dir, e))
+	if err != nil {
+		return time.Time{}
+	}
+	return fi.ModTime()
+}
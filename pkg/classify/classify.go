@@ -0,0 +1,296 @@
+// Package classify suggests tags with a naive Bayes model trained on the
+// entries that are already tagged.
+//
+// Features are the stemmed words of an entry's file name and, when the
+// folder has a full-text index (see the index command), of its content.
+// Every tag is scored on its own (one against the rest), so an entry can
+// receive several confident tags. The model is stored next to the catalog
+// or hub as .catmodel and retrained whenever a catalog or index changes.
+package classify
+
+import (
+	"encoding/gob"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/tenzokai/filemac/pkg/catalog"
+	"github.com/tenzokai/filemac/pkg/index"
+)
+
+// Filename is the model file stored next to .cat or .catlink.
+var Filename = ".catmodel"
+
+// minExamples is the number of tagged entries a tag needs to be learned.
+const minExamples = 2
+
+// Sample is a feature vector with the tags it is known to have.
+type Sample struct {
+	Features map[string]float64
+	Tags     []string
+}
+
+// Model holds the token counts of a multinomial naive Bayes classifier.
+type Model struct {
+	Stamp     string // state of the catalogs the model was trained on
+	Docs      int
+	TagDocs   map[string]int
+	TagTokens map[string]map[string]float64
+	TagTotal  map[string]float64
+	Tokens    map[string]float64
+	Total     float64
+}
+
+// Prediction is a suggested tag with the model's confidence in [0,1].
+type Prediction struct {
+	Tag        string
+	Confidence float64
+}
+
+// Features returns the features of an entry: file name words prefixed with
+// "n:" and, if doc is not nil, content words prefixed with "t:". Content
+// counts are damped so long documents do not drown the name.
+func Features(name string, doc *index.Doc) map[string]float64 {
+	f := map[string]float64{}
+	for stem, n := range index.Terms(strings.TrimSuffix(name, filepath.Ext(name))) {
+		f["n:"+stem] += float64(n)
+	}
+	if ext := strings.ToLower(strings.TrimPrefix(filepath.Ext(name), ".")); ext != "" {
+		f["x:"+ext] = 1
+	}
+	if doc != nil {
+		for stem, n := range doc.Terms {
+			f["t:"+stem] = 1 + math.Log(float64(n))
+		}
+	}
+	return f
+}
+
+// Train builds a model from samples. Tags with fewer than two examples
+// are ignored.
+func Train(samples []Sample) *Model {
+	m := &Model{
+		TagDocs:   map[string]int{},
+		TagTokens: map[string]map[string]float64{},
+		TagTotal:  map[string]float64{},
+		Tokens:    map[string]float64{},
+	}
+	count := map[string]int{}
+	for _, s := range samples {
+		for _, t := range s.Tags {
+			count[t]++
+		}
+	}
+	for _, s := range samples {
+		m.Docs++
+		for tok, v := range s.Features {
+			m.Tokens[tok] += v
+			m.Total += v
+		}
+		for _, t := range s.Tags {
+			if count[t] < minExamples {
+				continue
+			}
+			m.TagDocs[t]++
+			if m.TagTokens[t] == nil {
+				m.TagTokens[t] = map[string]float64{}
+			}
+			for tok, v := range s.Features {
+				m.TagTokens[t][tok] += v
+				m.TagTotal[t] += v
+			}
+		}
+	}
+	return m
+}
+
+// Predict scores every known tag for the features f, best first.
+func (m *Model) Predict(f map[string]float64) []Prediction {
+	vocab := float64(len(m.Tokens))
+	var out []Prediction
+	for tag, docs := range m.TagDocs {
+		if docs == m.Docs {
+			continue // a tag on everything carries no information
+		}
+		pos := m.TagTokens[tag]
+		posTotal := m.TagTotal[tag]
+		negTotal := m.Total - posTotal
+		// log odds of "has tag" against "does not have tag"
+		odds := math.Log(float64(docs)) - math.Log(float64(m.Docs-docs))
+		for tok, v := range f {
+			if _, known := m.Tokens[tok]; !known {
+				continue
+			}
+			p := (pos[tok] + 1) / (posTotal + vocab)
+			q := (m.Tokens[tok] - pos[tok] + 1) / (negTotal + vocab)
+			odds += v * (math.Log(p) - math.Log(q))
+		}
+		out = append(out, Prediction{Tag: tag, Confidence: 1 / (1 + math.Exp(-odds))})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Confidence != out[j].Confidence {
+			return out[i].Confidence > out[j].Confidence
+		}
+		return out[i].Tag < out[j].Tag
+	})
+	return out
+}
+
+// Load reads the model stored in dir.
+func Load(dir string) (*Model, error) {
+	f, err := os.Open(filepath.Join(dir, Filename))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	m := &Model{}
+	if err := gob.NewDecoder(f).Decode(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Save writes the model to dir, replacing the old file atomically.
+func (m *Model) Save(dir string) error {
+	tmp, err := os.CreateTemp(dir, Filename+".tmp*")
+	if err != nil {
+		return err
+	}
+	if err := gob.NewEncoder(tmp).Encode(m); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), filepath.Join(dir, Filename))
+}
+
+// stamp describes the size and modification time of the catalogs and
+// indexes a model for dir depends on.
+func stamp(sources []catalog.Source) string {
+	var b strings.Builder
+	for _, src := range sources {
+		for _, file := range []string{src.File, filepath.Join(src.Dir, index.Filename)} {
+			if fi, err := os.Stat(file); err == nil {
+				fmt.Fprintf(&b, "%s:%d:%d;", file, fi.Size(), fi.ModTime().UnixNano())
+			}
+		}
+	}
+	return b.String()
+}
+
+// Catalog is a loaded catalog with the full-text index of its folder.
+type Catalog struct {
+	catalog.Source
+	Entries []catalog.CatEntry
+	Index   *index.Index
+}
+
+// LoadCatalogs loads every catalog visible from dir with its index.
+func LoadCatalogs(dir string) ([]Catalog, error) {
+	sources, err := catalog.ResolveSources(dir)
+	if err != nil {
+		return nil, err
+	}
+	var out []Catalog
+	for _, src := range sources {
+		entries, err := catalog.LoadCatalogAt(src.File)
+		if err != nil {
+			return nil, err
+		}
+		ix, err := index.Load(src.Dir)
+		if err != nil {
+			ix = &index.Index{Docs: map[string]*index.Doc{}}
+		}
+		out = append(out, Catalog{Source: src, Entries: entries, Index: ix})
+	}
+	return out, nil
+}
+
+// EntryFeatures returns the features of entry i of c.
+func (c Catalog) EntryFeatures(i int) map[string]float64 {
+	e := c.Entries[i]
+	return Features(e.Name, c.Index.Docs[e.Name])
+}
+
+// ForDir returns the model for the catalogs visible from dir, training and
+// saving a new one when they changed since the stored model was built.
+func ForDir(dir string) (*Model, error) {
+	sources, err := catalog.ResolveSources(dir)
+	if err != nil {
+		return nil, err
+	}
+	st := stamp(sources)
+	if m, err := Load(dir); err == nil && m.Stamp == st {
+		return m, nil
+	}
+	cats, err := LoadCatalogs(dir)
+	if err != nil {
+		return nil, err
+	}
+	var samples []Sample
+	for _, c := range cats {
+		for i, e := range c.Entries {
+			if len(e.Tags) > 0 {
+				samples = append(samples, Sample{Features: c.EntryFeatures(i), Tags: e.Tags})
+			}
+		}
+	}
+	m := Train(samples)
+	m.Stamp = st
+	if err := m.Save(dir); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// CmdSuggest shows the most likely tags for an entry: suggest <num>.
+func CmdSuggest(w io.Writer, num string) error {
+	cwd, _ := os.Getwd()
+	entries, err := catalog.LoadCatalog()
+	if err != nil {
+		return err
+	}
+	i, err := catalog.EntryIndex(num, len(entries))
+	if err != nil {
+		return err
+	}
+	m, err := ForDir(cwd)
+	if err != nil {
+		return fmt.Errorf("suggest: %w", err)
+	}
+	if len(m.TagDocs) == 0 {
+		fmt.Fprintln(w, "not enough tagged entries to learn from")
+		return nil
+	}
+	ix, err := index.Load(cwd)
+	if err != nil {
+		ix = &index.Index{Docs: map[string]*index.Doc{}}
+	}
+	e := entries[i]
+	shown := 0
+	for _, p := range m.Predict(Features(e.Name, ix.Docs[e.Name])) {
+		if shown == 5 || p.Confidence < 0.01 {
+			break
+		}
+		mark := ""
+		for _, t := range e.Tags {
+			if t == p.Tag {
+				mark = " (has)"
+			}
+		}
+		fmt.Fprintf(w, "%-20s %5.1f%%%s\n", p.Tag, 100*p.Confidence, mark)
+		shown++
+	}
+	if shown == 0 {
+		fmt.Fprintln(w, "no suggestions")
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+package classify
+
+import (
+    "os"
+    "path/filepath"
+    "testing"
+
+    "github.com/tenzokai/filemac/pkg/catalog"
+)
+
+func TestForDirAndPredict(t *testing.T) {
+    dir := t.TempDir()
+    catfile := filepath.Join(dir, ".cat")
+    catalog.SaveCatalogAt(catfile, []catalog.CatEntry{
+        {Name: "Lohnsteuer_2022.pdf", Type: "file", Tags: []string{"steuer"}},
+        {Name: "Lohnsteuerbescheinigung_2023.pdf", Type: "file", Tags: []string{"steuer", "arbeit"}},
+        {Name: "Steuerbescheid_2023.pdf", Type: "file", Tags: []string{"steuer"}},
+        {Name: "Urlaub_Italien.jpg", Type: "file", Tags: []string{"urlaub"}},
+        {Name: "Urlaub_Spanien.jpg", Type: "file", Tags: []string{"urlaub"}},
+        {Name: "Arbeitsvertrag.pdf", Type: "file", Tags: []string{"arbeit"}},
+        {Name: "Lohnsteuer_2024.pdf", Type: "file"},
+    })
+    m, err := ForDir(dir)
+    if err != nil {
+        t.Fatalf("ForDir: %v", err)
+    }
+    if _, err := os.Stat(filepath.Join(dir, Filename)); err != nil {
+        t.Errorf("model not saved: %v", err)
+    }
+    p := m.Predict(Features("Lohnsteuer_2024.pdf", nil))
+    if len(p) == 0 || p[0].Tag != "steuer" || p[0].Confidence < 0.6 {
+        t.Fatalf("unexpected predictions %+v", p)
+    }
+    for _, q := range p {
+        if q.Tag == "urlaub" && q.Confidence > 0.1 {
+            t.Errorf("urlaub too likely: %+v", q)
+        }
+    }
+    again, err := ForDir(dir)
+    if err != nil || again.Stamp != m.Stamp {
+        t.Errorf("model was not reused: %v", err)
+    }
+}
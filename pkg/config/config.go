@@ -0,0 +1,675 @@
+package config
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Filename is the name of the user configuration file inside Dir().
+var Filename = "config.toml"
+
+// FolderFilename is the per-folder configuration file. Its values override
+// the user configuration for commands run in that folder.
+var FolderFilename = ".catconfig"
+
+// FolderSections are the sections a .catconfig may set. Openers and
+// aliases run commands, so they are only read from the user's own file and
+// not from folders that may come from someone else.
+var FolderSections = []string{"view", "tags", "catalog"}
+
+// Config holds the user settings read from config.toml and .catconfig.
+type Config struct {
+	// Templates maps preset names to search output templates ([templates]).
+	Templates map[string]string
+	// Opener configures how files and URLs are opened ([opener]).
+	Opener Opener
+	// Watch configures the folder watcher ([watch]).
+	Watch Watch
+	// Trash configures where rm puts files ([trash]).
+	Trash Trash
+	// Init configures the init command ([init]).
+	Init Init
+	// View configures catalog tables ([view]).
+	View View
+	// Tags configures the tag vocabulary ([tags]).
+	Tags Tags
+	// Catalog configures which files are cataloged ([catalog]).
+	Catalog Catalog
+	// Aliases maps alias names to the command line they stand for ([alias]).
+	Aliases map[string]string
+
+	// Origin records the file each set key came from; keys not listed keep
+	// their default.
+	Origin map[string]string
+	// Problems lists unknown keys and invalid values, prefixed with the file.
+	Problems []string
+}
+
+// Opener holds the commands used to open files and URLs. Commands may
+// contain "{}" as a placeholder for the path; otherwise it is appended.
+type Opener struct {
+	Default string            // command for all files (default: open / xdg-open)
+	Browser string            // command for URL entries (default: Default)
+	Ext     map[string]string // per-extension overrides without the dot ([opener.ext])
+}
+
+// Watch holds the settings of the watch command.
+type Watch struct {
+	Renames  bool // keep tags of renamed and moved files
+	Interval int  // polling interval in seconds when inotify is unavailable
+}
+
+// Trash holds the settings of rm and the trash commands.
+type Trash struct {
+	System bool // use the freedesktop trash instead of the folder's .cattrash
+}
+
+// Init holds the settings of the init command.
+type Init struct {
+	// ConfirmPercent is the share of entries init may remove without
+	// asking first.
+	ConfirmPercent int
+}
+
+// View holds the settings of catalog tables.
+type View struct {
+	Sort    string   // default order of vc, empty keeps catalog order
+	Columns []string // columns of vc, from ViewColumns
+	Color   bool     // highlight table headers on terminals
+}
+
+// Tags holds the tag vocabulary settings.
+type Tags struct {
+	// Vocabulary lists the allowed tags; empty means the tags in use.
+	Vocabulary []string
+	// Strictness is what happens to a tag outside the vocabulary: "off"
+	// allows it, "warn" allows it with a note, "strict" refuses it.
+	Strictness string
+}
+
+// Catalog holds the settings for cataloging files.
+type Catalog struct {
+	// Ignore lists glob patterns of file names init and watch leave out.
+	Ignore []string
+}
+
+// Values accepted by the [view] and [tags] settings. The sort keys match
+// those of vc -sort.
+var (
+	SortKeys     = []string{"name", "date", "ext", "tags", "mtime"}
+	ViewColumns  = []string{"num", "type", "name", "title", "date", "tags"}
+	Strictnesses = []string{"off", "warn", "strict"}
+)
+
+// newConfig returns a configuration with default values.
+func newConfig() *Config {
+	return &Config{
+		Templates: map[string]string{},
+		Opener:    Opener{Ext: map[string]string{}},
+		Watch:     Watch{Interval: 2},
+		Init:      Init{ConfirmPercent: 20},
+		View:      View{Columns: []string{"num", "type", "name", "tags"}, Color: true},
+		Tags:      Tags{Strictness: "off"},
+		Aliases:   map[string]string{},
+		Origin:    map[string]string{},
+	}
+}
+
+// Dir returns the filemac configuration directory
+// ($XDG_CONFIG_HOME/filemac, falling back to ~/.config/filemac).
+func Dir() string {
+	base := os.Getenv("XDG_CONFIG_HOME")
+	if base == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		base = filepath.Join(home, ".config")
+	}
+	return filepath.Join(base, "filemac")
+}
+
+// Path returns the full path of the user configuration file.
+func Path() string {
+	dir := Dir()
+	if dir == "" {
+		return ""
+	}
+	return filepath.Join(dir, Filename)
+}
+
+// Load reads the user configuration file and the .catconfig of the current
+// folder. Missing files are not an error.
+func Load() (*Config, error) {
+	dir, _ := os.Getwd()
+	return LoadDir(dir)
+}
+
+// LoadDir reads the user configuration file and the .catconfig of dir,
+// whose values win.
+func LoadDir(dir string) (*Config, error) {
+	cfg := newConfig()
+	files := []string{Path()}
+	if dir != "" {
+		files = append(files, filepath.Join(dir, FolderFilename))
+	}
+	for i, path := range files {
+		if path == "" {
+			continue
+		}
+		f, err := os.Open(path)
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return cfg, err
+		}
+		values, err := parse(f)
+		f.Close()
+		if err != nil {
+			return cfg, fmt.Errorf("%s: %v", path, err)
+		}
+		cfg.apply(values, path, i > 0)
+	}
+	return cfg, nil
+}
+
+// Parse reads a configuration from r.
+func Parse(r io.Reader) (*Config, error) {
+	cfg := newConfig()
+	values, err := parse(r)
+	if err != nil {
+		return cfg, err
+	}
+	cfg.apply(values, "input", false)
+	return cfg, nil
+}
+
+// apply copies parsed values into the typed fields and records where they
+// came from. A folder file may only set keys in FolderSections.
+func (c *Config) apply(values map[string]interface{}, origin string, folder bool) {
+	for key, v := range values {
+		if section, _, _ := strings.Cut(key, "."); folder && !contains(FolderSections, section) {
+			c.Problems = append(c.Problems, fmt.Sprintf("%s: not allowed in %s: %s", origin, FolderFilename, key))
+			continue
+		}
+		if strings.HasPrefix(key, "opener.ext.") {
+			// extensions are stored lower case without the dot
+			key = "opener.ext." + strings.ToLower(strings.TrimPrefix(strings.TrimPrefix(key, "opener.ext."), "."))
+		}
+		if !c.set(key, v) {
+			c.Problems = append(c.Problems, fmt.Sprintf("%s: unknown key or invalid value: %s", origin, key))
+			continue
+		}
+		c.Origin[key] = origin
+	}
+	sort.Strings(c.Problems)
+}
+
+// set stores one value and reports whether key and value were understood.
+func (c *Config) set(key string, v interface{}) bool {
+	str, isStr := v.(string)
+	list, isList := v.([]string)
+	b, isBool := v.(bool)
+	n, isInt := v.(int64)
+	switch {
+	case strings.HasPrefix(key, "templates."):
+		if !isStr {
+			return false
+		}
+		c.Templates[strings.TrimPrefix(key, "templates.")] = str
+	case strings.HasPrefix(key, "alias."):
+		if !isStr {
+			return false
+		}
+		c.Aliases[strings.TrimPrefix(key, "alias.")] = str
+	case key == "opener.default", key == "opener.browser", strings.HasPrefix(key, "opener.ext."):
+		if !isStr {
+			return false
+		}
+		switch key {
+		case "opener.default":
+			c.Opener.Default = str
+		case "opener.browser":
+			c.Opener.Browser = str
+		default:
+			c.Opener.Ext[strings.TrimPrefix(key, "opener.ext.")] = str
+		}
+	case key == "trash.system", key == "watch.renames", key == "view.color":
+		if !isBool {
+			return false
+		}
+		switch key {
+		case "trash.system":
+			c.Trash.System = b
+		case "watch.renames":
+			c.Watch.Renames = b
+		default:
+			c.View.Color = b
+		}
+	case key == "watch.interval":
+		if !isInt || n <= 0 {
+			return false
+		}
+		c.Watch.Interval = int(n)
+	case key == "init.confirm_percent":
+		if !isInt || n < 0 || n > 100 {
+			return false
+		}
+		c.Init.ConfirmPercent = int(n)
+	case key == "view.sort":
+		if !isStr || (str != "" && !contains(SortKeys, str)) {
+			return false
+		}
+		c.View.Sort = str
+	case key == "view.columns":
+		if !isList || len(list) == 0 {
+			return false
+		}
+		for _, col := range list {
+			if !contains(ViewColumns, col) {
+				return false
+			}
+		}
+		c.View.Columns = list
+	case key == "tags.vocabulary", key == "catalog.ignore":
+		if !isList {
+			return false
+		}
+		if key == "tags.vocabulary" {
+			c.Tags.Vocabulary = list
+		} else {
+			c.Catalog.Ignore = list
+		}
+	case key == "tags.strictness":
+		if !isStr || !contains(Strictnesses, str) {
+			return false
+		}
+		c.Tags.Strictness = str
+	default:
+		return false
+	}
+	return true
+}
+
+// Ignored reports whether a file name matches one of the [catalog] ignore
+// patterns.
+func (c *Config) Ignored(name string) bool {
+	for _, pattern := range c.Catalog.Ignore {
+		if ok, _ := filepath.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// SetAlias stores an alias in the [alias] table of the user configuration
+// file, replacing an alias of the same name. An empty value removes it.
+// The rest of the file is kept as it is.
+func SetAlias(name, value string) error {
+	for _, r := range name {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_') {
+			return fmt.Errorf("invalid alias name %q", name)
+		}
+	}
+	path := Path()
+	if path == "" || name == "" {
+		return fmt.Errorf("no alias name or configuration directory")
+	}
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	lines := strings.Split(strings.TrimRight(string(data), "\n"), "\n")
+	if len(data) == 0 {
+		lines = nil
+	}
+	entry := name + " = " + quote(value)
+	var out []string
+	table, inserted := "", false
+	aliasEnd := -1
+	for _, line := range lines {
+		trimmed := strings.TrimSpace(stripComment(line))
+		if strings.HasPrefix(trimmed, "[") && strings.HasSuffix(trimmed, "]") {
+			table = strings.TrimSpace(trimmed[1 : len(trimmed)-1])
+		} else if table == "alias" {
+			if eq := keyEnd(trimmed); eq != -1 {
+				if key, err := parseKey(strings.TrimSpace(trimmed[:eq])); err == nil && key == name {
+					if value != "" && !inserted {
+						out = append(out, entry)
+						inserted = true
+					}
+					continue
+				}
+			}
+		}
+		out = append(out, line)
+		if table == "alias" && trimmed != "" {
+			aliasEnd = len(out)
+		}
+	}
+	switch {
+	case inserted || value == "":
+	case aliasEnd != -1:
+		out = append(out[:aliasEnd], append([]string{entry}, out[aliasEnd:]...)...)
+	default:
+		if len(out) > 0 {
+			out = append(out, "")
+		}
+		out = append(out, "[alias]", entry)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, []byte(strings.Join(out, "\n")+"\n"), 0644)
+}
+
+// quote writes s as a basic string that parseString reads back.
+func quote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\t", `\t`).Replace(s) + `"`
+}
+
+// Setting is one effective configuration value.
+type Setting struct {
+	Key    string
+	Value  string // in TOML syntax
+	Origin string // the file that set it, or "default"
+}
+
+// Settings returns every effective value: the fixed keys in table order,
+// followed by the entries of [templates], [opener.ext] and [alias].
+func (c *Config) Settings() []Setting {
+	fixed := []struct {
+		key   string
+		value interface{}
+	}{
+		{"opener.default", c.Opener.Default},
+		{"opener.browser", c.Opener.Browser},
+		{"view.sort", c.View.Sort},
+		{"view.columns", c.View.Columns},
+		{"view.color", c.View.Color},
+		{"tags.strictness", c.Tags.Strictness},
+		{"tags.vocabulary", c.Tags.Vocabulary},
+		{"catalog.ignore", c.Catalog.Ignore},
+		{"init.confirm_percent", c.Init.ConfirmPercent},
+		{"watch.renames", c.Watch.Renames},
+		{"watch.interval", c.Watch.Interval},
+		{"trash.system", c.Trash.System},
+	}
+	var out []Setting
+	add := func(key string, value interface{}) {
+		origin := c.Origin[key]
+		if origin == "" {
+			origin = "default"
+		}
+		out = append(out, Setting{Key: key, Value: format(value), Origin: origin})
+	}
+	for _, f := range fixed {
+		add(f.key, f.value)
+	}
+	for _, m := range []struct {
+		prefix string
+		values map[string]string
+	}{{"templates.", c.Templates}, {"opener.ext.", c.Opener.Ext}, {"alias.", c.Aliases}} {
+		keys := make([]string, 0, len(m.values))
+		for k := range m.values {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			add(m.prefix+k, m.values[k])
+		}
+	}
+	return out
+}
+
+// format writes a value in TOML syntax.
+func format(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return strconv.Quote(v)
+	case []string:
+		quoted := make([]string, len(v))
+		for i, s := range v {
+			quoted[i] = strconv.Quote(s)
+		}
+		return "[" + strings.Join(quoted, ", ") + "]"
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+func contains(list []string, s string) bool {
+	for _, x := range list {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
+
+// CmdConfig shows the effective configuration of the current folder, each
+// value with the file it came from.
+func CmdConfig(w io.Writer) error {
+	cfg, err := Load()
+	if err != nil {
+		return err
+	}
+	cwd, _ := os.Getwd()
+	for _, path := range []string{Path(), filepath.Join(cwd, FolderFilename)} {
+		state := "not found"
+		if _, err := os.Stat(path); err == nil {
+			state = "read"
+		}
+		fmt.Fprintf(w, "# %s (%s)\n", path, state)
+	}
+	settings := cfg.Settings()
+	width := 0
+	for _, s := range settings {
+		if n := len(s.Key) + 3 + len(s.Value); n > width {
+			width = n
+		}
+	}
+	for _, s := range settings {
+		fmt.Fprintf(w, "%-*s  # %s\n", width, s.Key+" = "+s.Value, s.Origin)
+	}
+	for _, p := range cfg.Problems {
+		fmt.Fprintln(w, "warning:", p)
+	}
+	return nil
+}
+
+// parse understands the subset of TOML used by filemac: comments, [table]
+// headers, and key = value pairs where value is a string, integer, boolean
+// or an array of strings. Keys are returned fully qualified ("table.key").
+func parse(r io.Reader) (map[string]interface{}, error) {
+	values := make(map[string]interface{})
+	table := ""
+	scanner := bufio.NewScanner(r)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(stripComment(scanner.Text()))
+		if line == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "[") {
+			if !strings.HasSuffix(line, "]") {
+				return nil, fmt.Errorf("line %d: unterminated table header", lineNo)
+			}
+			name := strings.TrimSpace(line[1 : len(line)-1])
+			if name == "" {
+				return nil, fmt.Errorf("line %d: empty table name", lineNo)
+			}
+			table = name
+			continue
+		}
+		eq := keyEnd(line)
+		if eq == -1 {
+			return nil, fmt.Errorf("line %d: expected key = value", lineNo)
+		}
+		key, err := parseKey(strings.TrimSpace(line[:eq]))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", lineNo, err)
+		}
+		val, err := parseValue(strings.TrimSpace(line[eq+1:]))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", lineNo, err)
+		}
+		if table != "" {
+			key = table + "." + key
+		}
+		values[key] = val
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+// stripComment removes a trailing '#' comment that is not inside quotes.
+func stripComment(line string) string {
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '#':
+			return line[:i]
+		}
+	}
+	return line
+}
+
+// keyEnd returns the index of the '=' separating key and value.
+func keyEnd(line string) int {
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '=':
+			return i
+		}
+	}
+	return -1
+}
+
+func parseKey(s string) (string, error) {
+	if s == "" {
+		return "", fmt.Errorf("empty key")
+	}
+	if s[0] == '"' || s[0] == '\'' {
+		v, rest, err := parseString(s)
+		if err != nil {
+			return "", err
+		}
+		if strings.TrimSpace(rest) != "" {
+			return "", fmt.Errorf("unexpected text after key: %q", rest)
+		}
+		return v, nil
+	}
+	return s, nil
+}
+
+func parseValue(s string) (interface{}, error) {
+	if s == "" {
+		return nil, fmt.Errorf("missing value")
+	}
+	switch {
+	case s[0] == '"' || s[0] == '\'':
+		v, rest, err := parseString(s)
+		if err != nil {
+			return nil, err
+		}
+		if strings.TrimSpace(rest) != "" {
+			return nil, fmt.Errorf("unexpected text after value: %q", rest)
+		}
+		return v, nil
+	case s[0] == '[':
+		return parseArray(s)
+	case s == "true":
+		return true, nil
+	case s == "false":
+		return false, nil
+	}
+	n, err := strconv.ParseInt(strings.ReplaceAll(s, "_", ""), 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid value: %s", s)
+	}
+	return n, nil
+}
+
+// parseString reads a basic ("...") or literal ('...') string at the start
+// of s and returns it together with the remaining input.
+func parseString(s string) (string, string, error) {
+	quote := s[0]
+	var b strings.Builder
+	for i := 1; i < len(s); i++ {
+		c := s[i]
+		if c == quote {
+			return b.String(), s[i+1:], nil
+		}
+		if c == '\\' && quote == '"' && i+1 < len(s) {
+			i++
+			switch s[i] {
+			case 'n':
+				b.WriteByte('\n')
+			case 't':
+				b.WriteByte('\t')
+			case '"', '\\':
+				b.WriteByte(s[i])
+			default:
+				return "", "", fmt.Errorf("invalid escape \\%c", s[i])
+			}
+			continue
+		}
+		b.WriteByte(c)
+	}
+	return "", "", fmt.Errorf("unterminated string")
+}
+
+func parseArray(s string) ([]string, error) {
+	var out []string
+	rest := strings.TrimSpace(s[1:])
+	for {
+		if rest == "" {
+			return nil, fmt.Errorf("unterminated array")
+		}
+		if rest[0] == ']' {
+			if strings.TrimSpace(rest[1:]) != "" {
+				return nil, fmt.Errorf("unexpected text after array")
+			}
+			return out, nil
+		}
+		if rest[0] != '"' && rest[0] != '\'' {
+			return nil, fmt.Errorf("arrays may only contain strings")
+		}
+		v, r, err := parseString(rest)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, v)
+		rest = strings.TrimSpace(r)
+		if strings.HasPrefix(rest, ",") {
+			rest = strings.TrimSpace(rest[1:])
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package config
+
+import (
+    "os"
+    "path/filepath"
+    "strings"
+    "testing"
+)
+
+func TestParse(t *testing.T) {
+    src := `
+# search presets
+[templates]
+tsv = "{{.Path}}\t{{join .Tags \",\"}}"  # tab separated
+"paths only" = '{{.Path}}'
+`
+    cfg, err := Parse(strings.NewReader(src))
+    if err != nil {
+        t.Fatalf("Parse: %v", err)
+    }
+    if cfg.Templates["tsv"] != "{{.Path}}\t{{join .Tags \",\"}}" {
+        t.Errorf("tsv preset: %q", cfg.Templates["tsv"])
+    }
+    if cfg.Templates["paths only"] != "{{.Path}}" {
+        t.Errorf("quoted key preset: %q", cfg.Templates["paths only"])
+    }
+    if _, err := Parse(strings.NewReader("[templates\nx = 1")); err == nil {
+        t.Error("expected error for broken table header")
+    }
+}
+
+func TestLoadDirOverrideAndOrigin(t *testing.T) {
+    home := t.TempDir()
+    folder := t.TempDir()
+    t.Setenv("XDG_CONFIG_HOME", home)
+    os.MkdirAll(filepath.Join(home, "filemac"), 0755)
+    user := filepath.Join(home, "filemac", Filename)
+    os.WriteFile(user, []byte("[view]\nsort = \"name\"\ncolor = false\n[tags]\nstrictness = \"warn\"\n[opener.ext]\n\".PDF\" = \"zathura\"\n[alias]\nll = \"vc -l\"\n"), 0644)
+    os.WriteFile(filepath.Join(folder, FolderFilename), []byte("[view]\nsort = \"date\"\ncolumns = [\"num\", \"name\"]\n[catalog]\nignore = [\"*.part\"]\n[tags]\nstrictness = \"sometimes\"\n"), 0644)
+
+    cfg, err := LoadDir(folder)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if cfg.View.Sort != "date" || cfg.View.Color || len(cfg.View.Columns) != 2 || cfg.Aliases["ll"] != "vc -l" {
+        t.Errorf("view = %+v, aliases = %v", cfg.View, cfg.Aliases)
+    }
+    if cfg.Tags.Strictness != "warn" || len(cfg.Problems) != 1 || !strings.Contains(cfg.Problems[0], "tags.strictness") {
+        t.Errorf("strictness %q, problems %v", cfg.Tags.Strictness, cfg.Problems)
+    }
+    if !cfg.Ignored("film.mkv.part") || cfg.Ignored("film.mkv") {
+        t.Error("ignore patterns not applied")
+    }
+    origin := map[string]string{}
+    for _, s := range cfg.Settings() {
+        origin[s.Key] = s.Origin
+    }
+    if origin["view.sort"] != filepath.Join(folder, FolderFilename) || origin["view.color"] != user ||
+        origin["watch.interval"] != "default" || origin["opener.ext.pdf"] != user {
+        t.Errorf("origins = %v", origin)
+    }
+}
+
+func TestFolderConfigRestricted(t *testing.T) {
+    home := t.TempDir()
+    folder := t.TempDir()
+    t.Setenv("XDG_CONFIG_HOME", home)
+    os.WriteFile(filepath.Join(folder, FolderFilename), []byte("[opener]\ndefault = \"rm -rf\"\n[opener.ext]\npdf = \"sh\"\n[alias]\nls = \"rm 1-99\"\n[watch]\ninterval = 5\n[view]\nsort = \"date\"\n"), 0644)
+
+    cfg, err := LoadDir(folder)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if cfg.Opener.Default != "" || len(cfg.Opener.Ext) != 0 || len(cfg.Aliases) != 0 || cfg.Watch.Interval != 2 {
+        t.Errorf("folder file set restricted keys: opener %+v, aliases %v, watch %+v", cfg.Opener, cfg.Aliases, cfg.Watch)
+    }
+    if cfg.View.Sort != "date" {
+        t.Errorf("view.sort = %q, want date", cfg.View.Sort)
+    }
+    if len(cfg.Problems) != 4 {
+        t.Fatalf("problems = %v", cfg.Problems)
+    }
+    for _, p := range cfg.Problems {
+        if !strings.Contains(p, "not allowed in "+FolderFilename) {
+            t.Errorf("unexpected problem %q", p)
+        }
+    }
+}
+
+func TestSetAlias(t *testing.T) {
+    t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+    os.MkdirAll(Dir(), 0755)
+    os.WriteFile(Path(), []byte("# mine\n[alias]\nll = \"vc -l\"\n\n[view]\nsort = \"name\"\n"), 0644)
+    if err := SetAlias("bez", `r $1 offen "bezahlt"; a $1 2024`); err != nil {
+        t.Fatal(err)
+    }
+    if err := SetAlias("ll", ""); err != nil {
+        t.Fatal(err)
+    }
+    if err := SetAlias("bad name", "x"); err == nil {
+        t.Error("alias name with a space accepted")
+    }
+    cfg, err := LoadDir("")
+    if err != nil {
+        t.Fatal(err)
+    }
+    if cfg.Aliases["bez"] != `r $1 offen "bezahlt"; a $1 2024` || cfg.Aliases["ll"] != "" || cfg.View.Sort != "name" {
+        t.Errorf("aliases %q, sort %q", cfg.Aliases, cfg.View.Sort)
+    }
+    if data, _ := os.ReadFile(Path()); !strings.HasPrefix(string(data), "# mine\n[alias]\nbez = ") {
+        t.Errorf("file rewritten as\n%s", data)
+    }
+}
@@ -0,0 +1,314 @@
+package csvio
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/tenzokai/filemac/pkg/catalog"
+	"github.com/tenzokai/filemac/pkg/tags"
+)
+
+// header is the column layout written by export and understood by import.
+var header = []string{"name", "path", "type", "tags"}
+
+// tagSeparator joins tags inside the tags column.
+const tagSeparator = ", "
+
+// CmdExport writes catalog entries as CSV:
+// export csv [-o file | file.csv | -] [tag...]. Without a file the CSV goes
+// to w; a first argument only names the file when it ends in .csv (or is
+// given with -o), so other words are tag terms. Tag terms filter the
+// entries like a search and work across .catlink folders.
+func CmdExport(w io.Writer, args []string) error {
+	if len(args) == 0 || args[0] != "csv" {
+		return fmt.Errorf("usage: export csv [-o file | file.csv | -] [tag...]")
+	}
+	args = args[1:]
+	file := ""
+	switch {
+	case len(args) >= 2 && args[0] == "-o":
+		file, args = args[1], args[2:]
+	case len(args) >= 1 && args[0] == "-":
+		args = args[1:]
+	case len(args) >= 1 && strings.EqualFold(filepath.Ext(args[0]), ".csv"):
+		file, args = args[0], args[1:]
+	case len(args) >= 1 && args[0] == "-o":
+		return fmt.Errorf("usage: export csv -o <file> [tag...]")
+	}
+	out := w
+	if file != "" && file != "-" {
+		f, err := os.Create(file)
+		if err != nil {
+			return fmt.Errorf("export: %w", err)
+		}
+		defer f.Close()
+		out = f
+	}
+	cwd, _ := os.Getwd()
+	n, err := Export(out, cwd, args)
+	if err != nil {
+		return fmt.Errorf("export: %w", err)
+	}
+	if out != w {
+		fmt.Fprintf(w, "exported %d entries\n", n)
+	}
+	return nil
+}
+
+// Export writes all entries visible from dir that match terms to w and
+// returns the number of rows written (excluding the header).
+func Export(w io.Writer, dir string, terms []string) (int, error) {
+	sources, err := catalog.ResolveSources(dir)
+	if err != nil {
+		return 0, err
+	}
+	cw := csv.NewWriter(w)
+	if err := cw.Write(header); err != nil {
+		return 0, err
+	}
+	count := 0
+	for _, src := range sources {
+		entries, err := catalog.LoadCatalogAt(src.File)
+		if err != nil {
+			return count, err
+		}
+		for _, e := range entries {
+			if !tags.MatchEntry(terms, e) {
+				continue
+			}
+			row := []string{e.Name, catalog.EntryPath(src.Dir, e), e.Type, strings.Join(e.Tags, tagSeparator)}
+			if err := cw.Write(row); err != nil {
+				return count, err
+			}
+			count++
+		}
+	}
+	cw.Flush()
+	return count, cw.Error()
+}
+
+// ImportReport describes what an import did or, in dry-run mode, would do.
+type ImportReport struct {
+	Updated   []string // "name: old -> new"
+	Unchanged int
+	Unmatched []string // rows without a matching catalog entry
+	Conflicts []string // rows that disagree with each other or are ambiguous
+}
+
+// CmdImport applies tags from a CSV file: import csv [-n] file.
+// With -n (or --dry-run) nothing is saved and the planned changes are listed.
+func CmdImport(w io.Writer, args []string) error {
+	if len(args) == 0 || args[0] != "csv" {
+		return fmt.Errorf("usage: import csv [-n] <file>")
+	}
+	dryRun := false
+	file := ""
+	for _, a := range args[1:] {
+		switch a {
+		case "-n", "--dry-run":
+			dryRun = true
+		default:
+			file = a
+		}
+	}
+	if file == "" {
+		return fmt.Errorf("usage: import csv [-n] <file>")
+	}
+	f, err := os.Open(file)
+	if err != nil {
+		return fmt.Errorf("import: %w", err)
+	}
+	defer f.Close()
+	cwd, _ := os.Getwd()
+	rep, err := Import(f, cwd, dryRun)
+	if err != nil {
+		return fmt.Errorf("import: %w", err)
+	}
+	verb := "updated"
+	if dryRun {
+		verb = "would update"
+	}
+	for _, u := range rep.Updated {
+		fmt.Fprintf(w, "%s %s\n", verb, u)
+	}
+	for _, u := range rep.Unmatched {
+		fmt.Fprintln(w, "unmatched:", u)
+	}
+	for _, c := range rep.Conflicts {
+		fmt.Fprintln(w, "conflict:", c)
+	}
+	fmt.Fprintf(w, "%d %s, %d unchanged, %d unmatched, %d conflicts\n",
+		len(rep.Updated), verb, rep.Unchanged, len(rep.Unmatched), len(rep.Conflicts))
+	if dryRun {
+		fmt.Fprintln(w, "(dry run, nothing saved)")
+	}
+	return nil
+}
+
+// Import reads CSV rows from r and sets the tags of matching entries in the
+// catalogs visible from dir. Rows are matched by the path column when it is
+// present, otherwise by name. Catalogs are only saved when dryRun is false.
+func Import(r io.Reader, dir string, dryRun bool) (*ImportReport, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	rows, err := cr.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("empty CSV file")
+	}
+	col := map[string]int{}
+	for i, h := range rows[0] {
+		col[strings.ToLower(strings.TrimSpace(h))] = i
+	}
+	_, hasName := col["name"]
+	_, hasPath := col["path"]
+	if _, ok := col["tags"]; !ok || (!hasName && !hasPath) {
+		return nil, fmt.Errorf("CSV header needs a tags column and a name or path column")
+	}
+	field := func(row []string, name string) string {
+		i, ok := col[name]
+		if !ok || i >= len(row) {
+			return ""
+		}
+		return strings.TrimSpace(row[i])
+	}
+
+	catalog.Lock()
+	defer catalog.Unlock()
+	sources, err := catalog.ResolveSources(dir)
+	if err != nil {
+		return nil, err
+	}
+	type ref struct{ src, idx int }
+	loaded := make([][]catalog.CatEntry, len(sources))
+	byPath := map[string]ref{}
+	byName := map[string][]ref{}
+	for s, src := range sources {
+		entries, err := catalog.LoadCatalogAt(src.File)
+		if err != nil {
+			return nil, err
+		}
+		loaded[s] = entries
+		for i, e := range entries {
+			byPath[catalog.EntryPath(src.Dir, e)] = ref{s, i}
+			byName[e.Name] = append(byName[e.Name], ref{s, i})
+		}
+	}
+
+	rep := &ImportReport{}
+	planned := map[ref][]string{}
+	order := []ref{}
+	bad := map[ref]bool{}
+	for n, row := range rows[1:] {
+		line := n + 2
+		name, path := field(row, "name"), field(row, "path")
+		if name == "" && path == "" {
+			continue
+		}
+		var target ref
+		if r, ok := byPath[path]; ok && path != "" {
+			target = r
+		} else if refs := byName[name]; len(refs) == 1 {
+			target = refs[0]
+		} else if len(refs) > 1 {
+			rep.Conflicts = append(rep.Conflicts, fmt.Sprintf("line %d: %s is in %d catalogs, add a path column", line, name, len(refs)))
+			continue
+		} else {
+			label := name
+			if label == "" {
+				label = path
+			}
+			rep.Unmatched = append(rep.Unmatched, fmt.Sprintf("line %d: %s", line, label))
+			continue
+		}
+		newTags := splitTags(field(row, "tags"))
+		if err := checkTags(newTags); err != nil {
+			rep.Conflicts = append(rep.Conflicts, fmt.Sprintf("line %d: %v", line, err))
+			continue
+		}
+		if prev, ok := planned[target]; ok {
+			if !sameTags(prev, newTags) && !bad[target] {
+				bad[target] = true
+				rep.Conflicts = append(rep.Conflicts, fmt.Sprintf("line %d: %s has different tags in several rows", line, loaded[target.src][target.idx].Name))
+			}
+			continue
+		}
+		planned[target] = newTags
+		order = append(order, target)
+	}
+
+	dirty := make([]bool, len(sources))
+	for _, t := range order {
+		if bad[t] {
+			continue
+		}
+		e := &loaded[t.src][t.idx]
+		newTags := planned[t]
+		if sameTags(e.Tags, newTags) {
+			rep.Unchanged++
+			continue
+		}
+		rep.Updated = append(rep.Updated, fmt.Sprintf("%s: %s -> %s", e.Name, strings.Join(e.Tags, tagSeparator), strings.Join(newTags, tagSeparator)))
+		e.Tags = newTags
+		dirty[t.src] = true
+	}
+	if dryRun {
+		return rep, nil
+	}
+	for s, src := range sources {
+		if !dirty[s] {
+			continue
+		}
+		if err := catalog.SaveCatalogAt(src.File, loaded[s]); err != nil {
+			return rep, err
+		}
+	}
+	return rep, nil
+}
+
+// splitTags parses a tags cell; tags may be separated by commas or '*'.
+func splitTags(cell string) []string {
+	var out []string
+	seen := map[string]bool{}
+	for _, t := range strings.FieldsFunc(cell, func(r rune) bool { return r == ',' || r == '*' }) {
+		t = strings.TrimSpace(t)
+		if t != "" && !seen[t] {
+			seen[t] = true
+			out = append(out, t)
+		}
+	}
+	return out
+}
+
+// checkTags returns the first error catalog.CheckTag reports for tags.
+func checkTags(tags []string) error {
+	for _, t := range tags {
+		if err := catalog.CheckTag(t); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sameTags compares two tag lists ignoring order.
+func sameTags(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	set := map[string]bool{}
+	for _, t := range a {
+		set[t] = true
+	}
+	for _, t := range b {
+		if !set[t] {
+			return false
+		}
+	}
+	return true
+}
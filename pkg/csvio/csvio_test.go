@@ -0,0 +1,82 @@
+package csvio
+
+import (
+    "os"
+    "path/filepath"
+    "strings"
+    "testing"
+
+    "github.com/tenzokai/filemac/pkg/catalog"
+)
+
+func TestExportImportRoundtrip(t *testing.T) {
+    dir := t.TempDir()
+    catfile := filepath.Join(dir, ".cat")
+    catalog.SaveCatalogAt(catfile, []catalog.CatEntry{
+        {Name: "a.pdf", Type: "file", Tags: []string{"steuer", "2024"}},
+        {Name: "b.pdf", Type: "file", Tags: []string{"privat"}},
+    })
+
+    var out strings.Builder
+    n, err := Export(&out, dir, []string{"steuer"})
+    if err != nil || n != 1 {
+        t.Fatalf("Export: n=%d err=%v", n, err)
+    }
+    if !strings.Contains(out.String(), filepath.Join(dir, "a.pdf")) || strings.Contains(out.String(), "b.pdf") {
+        t.Errorf("unexpected export:\n%s", out.String())
+    }
+
+    sheet := "name,tags\n" +
+        "a.pdf,\"steuer, 2024\"\n" +
+        "b.pdf,privat*bank\n" +
+        "b.pdf,other\n" +
+        "missing.pdf,x\n"
+    rep, err := Import(strings.NewReader(sheet), dir, true)
+    if err != nil {
+        t.Fatalf("Import: %v", err)
+    }
+    if rep.Unchanged != 1 || len(rep.Unmatched) != 1 || len(rep.Conflicts) != 1 || len(rep.Updated) != 0 {
+        t.Errorf("unexpected dry-run report: %+v", rep)
+    }
+
+    rep, err = Import(strings.NewReader("name,tags\nb.pdf,privat*bank\n"), dir, false)
+    if err != nil || len(rep.Updated) != 1 {
+        t.Fatalf("Import: %+v %v", rep, err)
+    }
+    entries, _ := catalog.LoadCatalogAt(catfile)
+    if len(entries[1].Tags) != 2 || entries[1].Tags[1] != "bank" {
+        t.Errorf("tags not imported: %v", entries[1].Tags)
+    }
+}
+
+func TestExportFileArgument(t *testing.T) {
+    dir := t.TempDir()
+    t.Chdir(dir)
+    catalog.SaveCatalogAt(".cat", []catalog.CatEntry{
+        {Name: "a.pdf", Type: "file", Tags: []string{"steuer"}},
+        {Name: "b.pdf", Type: "file", Tags: []string{"privat"}},
+    })
+
+    // a tag is a search term, not a file name
+    var out strings.Builder
+    if err := CmdExport(&out, []string{"csv", "steuer"}); err != nil {
+        t.Fatal(err)
+    }
+    if !strings.Contains(out.String(), "a.pdf") || strings.Contains(out.String(), "b.pdf") {
+        t.Errorf("export csv steuer wrote:\n%s", out.String())
+    }
+    if _, err := os.Stat("steuer"); !os.IsNotExist(err) {
+        t.Error("export csv steuer created a file")
+    }
+
+    for _, args := range [][]string{{"csv", "list.csv", "privat"}, {"csv", "-o", "list.txt", "privat"}} {
+        out.Reset()
+        if err := CmdExport(&out, args); err != nil {
+            t.Fatalf("%v: %v", args, err)
+        }
+        data, err := os.ReadFile(args[len(args)-2])
+        if err != nil || !strings.Contains(string(data), "b.pdf") || strings.Contains(string(data), "a.pdf") {
+            t.Errorf("%v wrote %q, %v", args, data, err)
+        }
+    }
+}
@@ -0,0 +1,313 @@
+// Package index maintains a local full-text index of cataloged documents.
+// Each catalog folder gets its own index file next to its .cat.
+package index
+
+import (
+	"encoding/gob"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/tenzokai/filemac/pkg/catalog"
+	"github.com/tenzokai/filemac/pkg/textract"
+)
+
+// Filename is the index file stored next to .cat.
+var Filename = ".catindex"
+
+// maxStoredText bounds the text kept per document for snippets.
+const maxStoredText = 256 << 10
+
+// Doc is the indexed content of one catalog entry.
+type Doc struct {
+	Name    string
+	ModTime int64
+	Size    int64
+	Text    string
+	Terms   map[string]int // stem -> count
+}
+
+// Index holds the documents of one catalog folder, keyed by entry name.
+type Index struct {
+	Docs map[string]*Doc
+}
+
+// Stats summarizes an Update.
+type Stats struct {
+	Indexed, Unchanged, Removed, Failed int
+}
+
+// Load reads the index of dir. A missing index is empty.
+func Load(dir string) (*Index, error) {
+	ix := &Index{Docs: map[string]*Doc{}}
+	f, err := os.Open(filepath.Join(dir, Filename))
+	if os.IsNotExist(err) {
+		return ix, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	if err := gob.NewDecoder(f).Decode(ix); err != nil {
+		return nil, err
+	}
+	if ix.Docs == nil {
+		ix.Docs = map[string]*Doc{}
+	}
+	return ix, nil
+}
+
+// Save writes the index of dir, replacing the old file atomically.
+func (ix *Index) Save(dir string) error {
+	tmp, err := os.CreateTemp(dir, Filename+".tmp*")
+	if err != nil {
+		return err
+	}
+	if err := gob.NewEncoder(tmp).Encode(ix); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), filepath.Join(dir, Filename))
+}
+
+// Update re-extracts the file entries of dir whose size or modification
+// time changed and drops documents that are no longer cataloged.
+func (ix *Index) Update(dir string, entries []catalog.CatEntry) Stats {
+	var st Stats
+	keep := map[string]bool{}
+	for _, e := range entries {
+		path := catalog.EntryPath(dir, e)
+		if e.Type != "file" || !textract.Supported(path) {
+			continue
+		}
+		fi, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+		keep[e.Name] = true
+		if d, ok := ix.Docs[e.Name]; ok && d.ModTime == fi.ModTime().UnixNano() && d.Size == fi.Size() {
+			st.Unchanged++
+			continue
+		}
+		doc := &Doc{Name: e.Name, ModTime: fi.ModTime().UnixNano(), Size: fi.Size()}
+		text, err := textract.Extract(path)
+		if err != nil {
+			// remember the file anyway so it is not retried until it changes
+			st.Failed++
+		} else {
+			st.Indexed++
+		}
+		doc.Terms = Terms(text)
+		if len(text) > maxStoredText {
+			text = text[:maxStoredText]
+		}
+		doc.Text = text
+		ix.Docs[e.Name] = doc
+	}
+	for name := range ix.Docs {
+		if !keep[name] {
+			delete(ix.Docs, name)
+			st.Removed++
+		}
+	}
+	return st
+}
+
+// Hit is one search result.
+type Hit struct {
+	Name    string
+	Score   float64
+	Snippet string
+}
+
+// Search returns the documents containing all query words (after
+// stemming), best matches first. Stop words in the query are ignored, as
+// they are when indexing.
+func (ix *Index) Search(words []string) []Hit {
+	var stems []string
+	for _, w := range words {
+		for _, t := range tokenize(w) {
+			if indexable(t.word) {
+				stems = append(stems, Stem(t.word))
+			}
+		}
+	}
+	if len(stems) == 0 {
+		return nil
+	}
+	df := map[string]int{}
+	for _, d := range ix.Docs {
+		for _, s := range stems {
+			if d.Terms[s] > 0 {
+				df[s]++
+			}
+		}
+	}
+	n := float64(len(ix.Docs))
+	var hits []Hit
+	for _, d := range ix.Docs {
+		score := 0.0
+		for _, s := range stems {
+			tf := d.Terms[s]
+			if tf == 0 {
+				score = -1
+				break
+			}
+			score += (1 + math.Log(float64(tf))) * math.Log(1+n/float64(df[s]))
+		}
+		if score < 0 {
+			continue
+		}
+		hits = append(hits, Hit{Name: d.Name, Score: score, Snippet: snippet(d.Text, stems)})
+	}
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		return hits[i].Name < hits[j].Name
+	})
+	return hits
+}
+
+// snippet returns the text around the first word matching one of stems.
+func snippet(text string, stems []string) string {
+	want := map[string]bool{}
+	for _, s := range stems {
+		want[s] = true
+	}
+	for _, t := range tokenize(text) {
+		if !want[Stem(t.word)] {
+			continue
+		}
+		start, end := t.start, t.end
+		for k := 0; k < 60 && start > 0; k++ {
+			start--
+			for start > 0 && !isRuneStart(text[start]) {
+				start--
+			}
+		}
+		for k := 0; k < 80 && end < len(text); k++ {
+			end++
+			for end < len(text) && !isRuneStart(text[end]) {
+				end++
+			}
+		}
+		s := strings.Join(strings.Fields(text[start:end]), " ")
+		if start > 0 {
+			s = "…" + s
+		}
+		if end < len(text) {
+			s += "…"
+		}
+		return s
+	}
+	return ""
+}
+
+func isRuneStart(b byte) bool {
+	return b&0xc0 != 0x80
+}
+
+// CmdIndex builds or refreshes the content index of the local catalog, or
+// of every linked catalog when only a .catlink exists.
+func CmdIndex(w io.Writer) error {
+	cwd, _ := os.Getwd()
+	sources, err := catalog.ResolveSources(cwd)
+	if err != nil {
+		return fmt.Errorf("index: %w", err)
+	}
+	var total Stats
+	for _, src := range sources {
+		entries, err := catalog.LoadCatalogAt(src.File)
+		if err != nil {
+			fmt.Fprintf(w, "error reading %s: %v\n", src.File, err)
+			continue
+		}
+		ix, err := Load(src.Dir)
+		if err != nil {
+			fmt.Fprintf(w, "index of %s unreadable, rebuilding: %v\n", src.Dir, err)
+			ix = &Index{Docs: map[string]*Doc{}}
+		}
+		st := ix.Update(src.Dir, entries)
+		if st.Indexed+st.Removed+st.Failed > 0 {
+			if err := ix.Save(src.Dir); err != nil {
+				fmt.Fprintf(w, "error saving index in %s: %v\n", src.Dir, err)
+				continue
+			}
+		}
+		total.Indexed += st.Indexed
+		total.Unchanged += st.Unchanged
+		total.Removed += st.Removed
+		total.Failed += st.Failed
+	}
+	fmt.Fprintf(w, "index updated: %d indexed, %d unchanged, %d removed, %d failed\n",
+		total.Indexed, total.Unchanged, total.Removed, total.Failed)
+	return nil
+}
+
+// CmdSearchText searches document contents: sf <words...>. It covers the
+// local catalog and all .catlink targets and prints path, tags and a snippet.
+func CmdSearchText(w io.Writer, words []string) error {
+	if len(words) == 0 {
+		return fmt.Errorf("usage: sf <word...>")
+	}
+	cwd, _ := os.Getwd()
+	sources, err := catalog.ResolveSources(cwd)
+	if err != nil {
+		return err
+	}
+	type result struct {
+		Hit
+		path string
+		tags []string
+	}
+	var results []result
+	unindexed := 0
+	for _, src := range sources {
+		if _, err := os.Stat(filepath.Join(src.Dir, Filename)); err != nil {
+			unindexed++
+			continue
+		}
+		ix, err := Load(src.Dir)
+		if err != nil {
+			fmt.Fprintf(w, "error reading index in %s: %v\n", src.Dir, err)
+			continue
+		}
+		entries, _ := catalog.LoadCatalogAt(src.File)
+		byName := map[string]catalog.CatEntry{}
+		for _, e := range entries {
+			byName[e.Name] = e
+		}
+		for _, h := range ix.Search(words) {
+			e, ok := byName[h.Name]
+			if !ok {
+				continue // stale index entry
+			}
+			results = append(results, result{h, catalog.EntryPath(src.Dir, e), e.Tags})
+		}
+	}
+	sort.SliceStable(results, func(i, j int) bool { return results[i].Score > results[j].Score })
+	for _, r := range results {
+		fmt.Fprintln(w, r.path)
+		fmt.Fprintln(w, "   "+strings.Join(r.tags, ", "))
+		if r.Snippet != "" {
+			fmt.Fprintln(w, "   "+r.Snippet)
+		}
+		fmt.Fprintln(w)
+	}
+	if len(results) == 0 {
+		fmt.Fprintln(w, "No matches.")
+	}
+	if unindexed > 0 {
+		fmt.Fprintf(w, "(%d catalog folders have no index yet, run 'index')\n", unindexed)
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package index
+
+import (
+    "os"
+    "path/filepath"
+    "strings"
+    "testing"
+    "time"
+
+    "github.com/tenzokai/filemac/pkg/catalog"
+)
+
+func TestStem(t *testing.T) {
+    pairs := [][2]string{
+        {"Kündigungen", "Kündigung"},
+        {"kündigen", "Kündigung"},
+        {"invoices", "invoice"},
+        {"payments", "payment"},
+    }
+    for _, p := range pairs {
+        if Stem(p[0]) != Stem(p[1]) {
+            t.Errorf("Stem(%q)=%q != Stem(%q)=%q", p[0], Stem(p[0]), p[1], Stem(p[1]))
+        }
+    }
+}
+
+func TestUpdateAndSearch(t *testing.T) {
+    dir := t.TempDir()
+    os.WriteFile(filepath.Join(dir, "a.txt"), []byte("Die Kündigungen der Verträge gehen an die Hausverwaltung."), 0644)
+    os.WriteFile(filepath.Join(dir, "b.html"), []byte("<p>Rechnung für die <b>Kündigung</b> und Versicherung</p>"), 0644)
+    os.WriteFile(filepath.Join(dir, "c.jpg"), []byte("binary"), 0644)
+    entries := []catalog.CatEntry{
+        {Name: "a.txt", Type: "file"}, {Name: "b.html", Type: "file"}, {Name: "c.jpg", Type: "file"},
+    }
+    ix, _ := Load(dir)
+    if st := ix.Update(dir, entries); st.Indexed != 2 {
+        t.Fatalf("expected 2 indexed docs, got %+v", st)
+    }
+    if err := ix.Save(dir); err != nil {
+        t.Fatalf("Save: %v", err)
+    }
+    ix, err := Load(dir)
+    if err != nil || len(ix.Docs) != 2 {
+        t.Fatalf("Load: %v %d", err, len(ix.Docs))
+    }
+
+    hits := ix.Search([]string{"kündigung"})
+    if len(hits) != 2 {
+        t.Fatalf("expected 2 hits, got %+v", hits)
+    }
+    hits = ix.Search([]string{"kündigen", "versicherung"})
+    if len(hits) != 1 || hits[0].Name != "b.html" || !strings.Contains(hits[0].Snippet, "Kündigung") {
+        t.Errorf("unexpected hits: %+v", hits)
+    }
+    for _, q := range [][]string{{"die", "kündigung"}, {"the", "a", "kündigung"}} {
+        if hits := ix.Search(q); len(hits) != 2 {
+            t.Errorf("Search(%q): expected stop words to be ignored, got %+v", q, hits)
+        }
+    }
+    if hits := ix.Search([]string{"die"}); len(hits) != 0 {
+        t.Errorf("a query of stop words only should not match: %+v", hits)
+    }
+
+    later := time.Now().Add(time.Minute)
+    os.WriteFile(filepath.Join(dir, "a.txt"), []byte("Steuerbescheid"), 0644)
+    os.Chtimes(filepath.Join(dir, "a.txt"), later, later)
+    st := ix.Update(dir, entries[:1])
+    if st.Indexed != 1 || st.Removed != 1 || st.Unchanged != 0 {
+        t.Errorf("unexpected update stats: %+v", st)
+    }
+}
@@ -0,0 +1,93 @@
+package index
+
+import (
+	"strings"
+	"unicode"
+)
+
+// token is a word of a text together with its byte position.
+type token struct {
+	word       string
+	start, end int
+}
+
+// tokenize splits text into lower-case words of letters and digits.
+func tokenize(text string) []token {
+	var toks []token
+	start := -1
+	for i, r := range text {
+		word := unicode.IsLetter(r) || unicode.IsDigit(r)
+		if word && start < 0 {
+			start = i
+		} else if !word && start >= 0 {
+			toks = append(toks, token{strings.ToLower(text[start:i]), start, i})
+			start = -1
+		}
+	}
+	if start >= 0 {
+		toks = append(toks, token{strings.ToLower(text[start:]), start, len(text)})
+	}
+	return toks
+}
+
+var stopWords = map[string]bool{
+	// German
+	"der": true, "die": true, "das": true, "und": true, "oder": true, "ein": true, "eine": true,
+	"einer": true, "eines": true, "dem": true, "den": true, "des": true, "ist": true, "im": true,
+	"in": true, "zu": true, "mit": true, "von": true, "auf": true, "für": true, "nicht": true,
+	"sie": true, "wir": true, "ich": true, "es": true, "an": true, "am": true, "bei": true,
+	// English
+	"the": true, "and": true, "or": true, "of": true, "to": true, "a": true,
+	"is": true, "are": true, "for": true, "on": true, "with": true, "by": true, "it": true,
+	"this": true, "that": true, "be": true, "as": true, "at": true, "from": true,
+}
+
+// umlauts folds German special letters so "Kündigung" and "Kundigung" meet.
+var umlauts = strings.NewReplacer("ä", "a", "ö", "o", "ü", "u", "ß", "ss")
+
+// suffixes are stripped by Stem, longest first. The list mixes common
+// German and English inflections; documents are not language-tagged.
+var suffixes = []string{
+	"heiten", "keiten", "ations", "ungen", "ation", "ness", "ment", "lich", "heit", "keit",
+	"isch", "ing", "ung", "ies", "ern", "ers", "est", "ed", "em", "en", "er", "es", "ly", "e", "s",
+}
+
+// Stem reduces a word to a light German/English stem: lower case, folded
+// umlauts, and up to two suffixes removed (so "payments" and "payment"
+// meet) while keeping at least three letters.
+func Stem(word string) string {
+	w := umlauts.Replace(strings.ToLower(word))
+	for pass := 0; pass < 2; pass++ {
+		w = stripSuffix(w)
+	}
+	return w
+}
+
+func stripSuffix(w string) string {
+	for _, suf := range suffixes {
+		if strings.HasSuffix(w, suf) && len([]rune(w))-len(suf) >= 3 {
+			if suf == "ies" {
+				return w[:len(w)-3] + "y"
+			}
+			return w[:len(w)-len(suf)]
+		}
+	}
+	return w
+}
+
+// indexable reports whether a lower-cased word is worth indexing: stop
+// words and single letters are not.
+func indexable(word string) bool {
+	return len(word) >= 2 && !stopWords[word]
+}
+
+// Terms returns the stems of the indexable words in text.
+func Terms(text string) map[string]int {
+	out := map[string]int{}
+	for _, t := range tokenize(text) {
+		if indexable(t.word) {
+			out[Stem(t.word)]++
+		}
+	}
+	return out
+}
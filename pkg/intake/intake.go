@@ -0,0 +1,325 @@
+// Package intake files new documents from an inbox folder into a catalog
+// folder under a dated, descriptive name.
+package intake
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/peterh/liner"
+	"github.com/tenzokai/filemac/pkg/catalog"
+	"github.com/tenzokai/filemac/pkg/opener"
+	"github.com/tenzokai/filemac/pkg/pdf"
+	"github.com/tenzokai/filemac/pkg/rules"
+	"github.com/tenzokai/filemac/pkg/utils"
+)
+
+const dateLayout = "2006-01-02"
+
+// errSkip and errQuit end the questions about one file.
+var (
+	errSkip = errors.New("skip")
+	errQuit = errors.New("quit")
+)
+
+// TargetName returns the archive name YYYY-MM-DD_Title.ext. Spaces in the
+// title become underscores; path separators and '*' are dropped.
+func TargetName(date time.Time, title, ext string) string {
+	title = strings.Map(func(r rune) rune {
+		switch r {
+		case '/', '\\', '*', ':':
+			return -1
+		}
+		return r
+	}, title)
+	title = strings.Join(strings.Fields(title), "_")
+	return date.Format(dateLayout) + "_" + title + strings.ToLower(ext)
+}
+
+// File moves the file src into archive as name and adds it with tags to the
+// archive's catalog. If the catalog cannot be saved the file is moved back,
+// so either both or neither change. An existing name gets a _2, _3, ...
+// suffix. File returns the name actually used.
+func File(src, archive, name string, tags []string) (string, error) {
+	catalog.Lock()
+	defer catalog.Unlock()
+	catfile := filepath.Join(archive, catalog.CatalogFilename)
+	entries, err := catalog.LoadCatalogAt(catfile)
+	if err != nil && !os.IsNotExist(err) {
+		return "", err
+	}
+	name = freeName(archive, name, entries)
+	dst := filepath.Join(archive, name)
+	if err := moveFile(src, dst); err != nil {
+		return "", err
+	}
+	entries = append(entries, catalog.CatEntry{Name: name, Type: "file", Tags: tags})
+	if err := catalog.SaveCatalogAt(catfile, entries); err != nil {
+		if merr := moveFile(dst, src); merr != nil {
+			return "", fmt.Errorf("%v (and could not move %s back: %v)", err, dst, merr)
+		}
+		return "", err
+	}
+	return name, nil
+}
+
+// freeName returns name, or name with a numeric suffix if a file or
+// catalog entry of that name exists in dir.
+func freeName(dir, name string, entries []catalog.CatEntry) string {
+	taken := func(n string) bool {
+		if _, err := os.Lstat(filepath.Join(dir, n)); err == nil {
+			return true
+		}
+		for _, e := range entries {
+			if e.Name == n {
+				return true
+			}
+		}
+		return false
+	}
+	ext := filepath.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+	for i := 2; taken(name); i++ {
+		name = fmt.Sprintf("%s_%d%s", base, i, ext)
+	}
+	return name
+}
+
+// moveFile renames src to dst, copying across file systems. It never
+// overwrites dst.
+func moveFile(src, dst string) error {
+	if _, err := os.Lstat(dst); err == nil {
+		return fmt.Errorf("%s already exists", dst)
+	}
+	if err := os.Rename(src, dst); err == nil {
+		return nil
+	}
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	fi, err := in.Stat()
+	if err != nil {
+		return err
+	}
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, fi.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(out, in); err == nil {
+		err = out.Sync()
+	}
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(dst)
+		return err
+	}
+	os.Chtimes(dst, fi.ModTime(), fi.ModTime())
+	return os.Remove(src)
+}
+
+// CmdIntake walks through the files in inbox and files each one into
+// archive after asking for its date, title and tags:
+// intake <inbox> <archive>.
+func CmdIntake(w io.Writer, args []string) error {
+	if len(args) != 2 {
+		return fmt.Errorf("usage: intake <inbox> <archive>")
+	}
+	inbox, archive := utils.ExpandHome(args[0]), utils.ExpandHome(args[1])
+	for _, dir := range []string{inbox, archive} {
+		if fi, err := os.Stat(dir); err != nil || !fi.IsDir() {
+			return fmt.Errorf("not a folder: %s", dir)
+		}
+	}
+	list, err := os.ReadDir(inbox)
+	if err != nil {
+		return fmt.Errorf("intake: %w", err)
+	}
+	var files []os.FileInfo
+	for _, f := range list {
+		if f.IsDir() || strings.HasPrefix(f.Name(), ".") {
+			continue
+		}
+		if fi, err := f.Info(); err == nil {
+			files = append(files, fi)
+		}
+	}
+	if len(files) == 0 {
+		fmt.Fprintln(w, "Inbox is empty.")
+		return nil
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].ModTime().Before(files[j].ModTime()) })
+
+	set, err := rules.Load(archive)
+	if err != nil {
+		return fmt.Errorf("intake: %w", err)
+	}
+	known := archiveTags(archive)
+	line := liner.NewLiner()
+	defer line.Close()
+	line.SetCtrlCAborts(true)
+
+	fmt.Fprintln(w, "At any question: 'o' opens the file, 's' skips it, 'q' quits.")
+	filed, skipped := 0, 0
+	for n, fi := range files {
+		src := filepath.Join(inbox, fi.Name())
+		fmt.Fprintf(w, "\nFile %d / %d: %s (%d KB, %s)\n", n+1, len(files), fi.Name(), (fi.Size()+1023)/1024, fi.ModTime().Format("2006-01-02 15:04"))
+		title := ""
+		if strings.EqualFold(filepath.Ext(fi.Name()), ".pdf") {
+			if r, err := pdf.Open(src); err == nil {
+				title = r.Info().Title
+				fmt.Fprintf(w, "  %d pages", r.NumPages())
+				if title != "" {
+					fmt.Fprintf(w, ", title: %s", title)
+				}
+				fmt.Fprintln(w)
+			}
+		}
+		ask := func(prompt, def string) (string, error) {
+			line.SetWordCompleter(nil)
+			if prompt == "tags> " {
+				line.SetWordCompleter(completer(known))
+			}
+			for {
+				in, err := line.PromptWithSuggestion(prompt, def, -1)
+				if err != nil {
+					fmt.Fprintln(w)
+					return "", errQuit
+				}
+				switch in = strings.TrimSpace(in); in {
+				case "o":
+					if err := opener.Open(src); err != nil {
+						fmt.Fprintln(w, "Error opening:", err)
+					}
+				case "s":
+					return "", errSkip
+				case "q":
+					return "", errQuit
+				default:
+					return in, nil
+				}
+			}
+		}
+
+		name, tags, err := questions(w, ask, fi, title, set, archive)
+		if err == errQuit {
+			break
+		}
+		if err == errSkip {
+			skipped++
+			continue
+		}
+		used, err := File(src, archive, name, tags)
+		if err != nil {
+			fmt.Fprintln(w, "intake error:", err)
+			skipped++
+			continue
+		}
+		for _, t := range tags {
+			if !contains(known, t) {
+				known = append(known, t)
+			}
+		}
+		filed++
+		fmt.Fprintf(w, "Filed as %s\n", used)
+	}
+	fmt.Fprintf(w, "Filed %d, skipped %d, %d left in inbox.\n", filed, skipped, len(files)-filed-skipped)
+	return nil
+}
+
+// questions asks for date, title and tags and returns the archive name and
+// tags of the file.
+func questions(w io.Writer, ask func(prompt, def string) (string, error), fi os.FileInfo, title string, set *rules.Set, archive string) (string, []string, error) {
+	var date time.Time
+	for {
+		in, err := ask("date> ", fi.ModTime().Format(dateLayout))
+		if err != nil {
+			return "", nil, err
+		}
+		if date, err = time.ParseInLocation(dateLayout, in, time.Local); err == nil {
+			break
+		}
+		fmt.Fprintln(w, "date must look like 2024-05-02")
+	}
+	for {
+		in, err := ask("title> ", title)
+		if err != nil {
+			return "", nil, err
+		}
+		if title = in; title != "" {
+			break
+		}
+		fmt.Fprintln(w, "a title is needed")
+	}
+	name := TargetName(date, title, filepath.Ext(fi.Name()))
+	var suggested []string
+	for _, t := range append(append([]string{}, set.Defaults...), set.Match(archive, name)...) {
+		if !contains(suggested, t) {
+			suggested = append(suggested, t)
+		}
+	}
+	in, err := ask("tags> ", strings.Join(suggested, ", "))
+	if err != nil {
+		return "", nil, err
+	}
+	var tags []string
+	for _, t := range strings.FieldsFunc(in, func(r rune) bool { return r == ',' || r == ' ' || r == '*' }) {
+		if err := catalog.CheckTag(t); err != nil {
+			fmt.Fprintf(w, "skipped: %v\n", err)
+			continue
+		}
+		if !contains(tags, t) {
+			tags = append(tags, t)
+		}
+	}
+	return name, tags, nil
+}
+
+// archiveTags returns the tags used in the archive's catalog.
+func archiveTags(archive string) []string {
+	entries, _ := catalog.LoadCatalogAt(filepath.Join(archive, catalog.CatalogFilename))
+	var tags []string
+	for _, e := range entries {
+		for _, t := range e.Tags {
+			if !contains(tags, t) {
+				tags = append(tags, t)
+			}
+		}
+	}
+	sort.Strings(tags)
+	return tags
+}
+
+// completer completes the last word of the tag line.
+func completer(tags []string) liner.WordCompleter {
+	return func(line string, pos int) (string, []string, string) {
+		r := []rune(line)
+		head := string(r[:pos])
+		start := strings.LastIndexAny(head, ", ") + 1
+		var out []string
+		for _, t := range tags {
+			if strings.HasPrefix(t, head[start:]) {
+				out = append(out, t)
+			}
+		}
+		return head[:start], out, string(r[pos:])
+	}
+}
+
+func contains(tags []string, tag string) bool {
+	for _, t := range tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,61 @@
+package intake
+
+import (
+    "io"
+    "os"
+    "path/filepath"
+    "strings"
+    "testing"
+    "time"
+
+    "github.com/tenzokai/filemac/pkg/catalog"
+    "github.com/tenzokai/filemac/pkg/rules"
+)
+
+func TestTargetName(t *testing.T) {
+    d := time.Date(2024, 5, 2, 0, 0, 0, 0, time.Local)
+    if got := TargetName(d, " Lohnsteuer  Allianz/2023* ", ".PDF"); got != "2024-05-02_Lohnsteuer_Allianz2023.pdf" {
+        t.Errorf("TargetName = %q", got)
+    }
+}
+
+func TestFileAndQuestions(t *testing.T) {
+    inbox, archive := t.TempDir(), t.TempDir()
+    src := filepath.Join(inbox, "SCAN0042.pdf")
+    os.WriteFile(src, []byte("scan"), 0644)
+    mtime := time.Date(2024, 5, 2, 10, 0, 0, 0, time.Local)
+    os.Chtimes(src, mtime, mtime)
+    os.WriteFile(filepath.Join(archive, "2024-05-02_Allianz_Police.pdf"), []byte("older"), 0644)
+    catalog.SaveCatalogAt(filepath.Join(archive, ".cat"), []catalog.CatEntry{{Name: "2024-05-02_Allianz_Police.pdf", Type: "file"}})
+
+    set, _ := rules.Parse(strings.NewReader("*allianz* -> versicherung\n"))
+    fi, _ := os.Stat(src)
+    var prompts []string
+    answers := map[string]string{"title> ": "Allianz Police"}
+    ask := func(prompt, def string) (string, error) {
+        prompts = append(prompts, prompt+def)
+        if a, ok := answers[prompt]; ok {
+            return a, nil
+        }
+        return def, nil
+    }
+    name, tags, err := questions(io.Discard, ask, fi, "", set, archive)
+    if err != nil || name != "2024-05-02_Allianz_Police.pdf" || strings.Join(tags, ",") != "versicherung" {
+        t.Fatalf("questions = %q %v %v (prompts %q)", name, tags, err, prompts)
+    }
+    if prompts[0] != "date> 2024-05-02" {
+        t.Errorf("date default: %q", prompts[0])
+    }
+
+    used, err := File(src, archive, name, append(tags, "2024"))
+    if err != nil || used != "2024-05-02_Allianz_Police_2.pdf" {
+        t.Fatalf("File = %q, %v", used, err)
+    }
+    if _, err := os.Stat(src); !os.IsNotExist(err) {
+        t.Error("source still in inbox")
+    }
+    entries, _ := catalog.LoadCatalogAt(filepath.Join(archive, ".cat"))
+    if len(entries) != 2 || entries[1].Name != used || strings.Join(entries[1].Tags, ",") != "versicherung,2024" {
+        t.Errorf("catalog: %+v", entries)
+    }
+}
@@ -0,0 +1,132 @@
+package opener
+
+import (
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/tenzokai/filemac/pkg/config"
+)
+
+// DefaultCommand returns the platform's standard "open with default
+// application" command.
+func DefaultCommand() string {
+	switch runtime.GOOS {
+	case "darwin":
+		return "open"
+	case "windows":
+		return `cmd /c start ""`
+	}
+	return "xdg-open"
+}
+
+// Open opens a file path or URL with the command configured for it.
+func Open(target string) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+	argv, err := Command(cfg.Opener, target)
+	if err != nil {
+		return err
+	}
+	cmd := exec.Command(argv[0], argv[1:]...)
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	// Viewers may keep running; reap them in the background.
+	go cmd.Wait()
+	return nil
+}
+
+// Command returns the argv used to open target: the browser for URLs, a
+// per-extension override for files, or the default opener.
+func Command(o config.Opener, target string) ([]string, error) {
+	command := o.Default
+	if IsURL(target) {
+		if o.Browser != "" {
+			command = o.Browser
+		}
+	} else {
+		ext := strings.ToLower(strings.TrimPrefix(filepath.Ext(target), "."))
+		if c, ok := o.Ext[ext]; ok && ext != "" {
+			command = c
+		}
+	}
+	if command == "" {
+		command = DefaultCommand()
+	}
+	argv, err := splitCommand(command)
+	if err != nil {
+		return nil, err
+	}
+	if len(argv) == 0 {
+		return nil, fmt.Errorf("empty opener command")
+	}
+	replaced := false
+	for i, a := range argv {
+		if strings.Contains(a, "{}") {
+			argv[i] = strings.ReplaceAll(a, "{}", target)
+			replaced = true
+		}
+	}
+	if !replaced {
+		argv = append(argv, target)
+	}
+	return argv, nil
+}
+
+// IsURL reports whether target has a URL scheme such as https: or mailto:.
+func IsURL(target string) bool {
+	i := strings.Index(target, ":")
+	if i < 2 {
+		// no scheme, or a Windows drive letter
+		return false
+	}
+	for _, r := range target[:i] {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '+' || r == '-' || r == '.') {
+			return false
+		}
+	}
+	return true
+}
+
+// splitCommand splits a command line on spaces, honouring single and
+// double quotes.
+func splitCommand(s string) ([]string, error) {
+	var args []string
+	var cur strings.Builder
+	inArg := false
+	var quote rune
+	for _, r := range s {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				cur.WriteRune(r)
+			}
+		case r == '"' || r == '\'':
+			quote = r
+			inArg = true
+		case r == ' ' || r == '\t':
+			if inArg {
+				args = append(args, cur.String())
+				cur.Reset()
+				inArg = false
+			}
+		default:
+			cur.WriteRune(r)
+			inArg = true
+		}
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated quote in %q", s)
+	}
+	if inArg {
+		args = append(args, cur.String())
+	}
+	return args, nil
+}
@@ -0,0 +1,40 @@
+package opener
+
+import (
+    "testing"
+
+    "github.com/tenzokai/filemac/pkg/config"
+)
+
+func TestCommand(t *testing.T) {
+    o := config.Opener{
+        Default: "xdg-open",
+        Browser: "firefox --new-tab",
+        Ext:     map[string]string{"pdf": `"/opt/My Viewer/bin/view" --page 1 {}`},
+    }
+    cases := []struct {
+        target string
+        want   []string
+    }{
+        {"/docs/a.PDF", []string{"/opt/My Viewer/bin/view", "--page", "1", "/docs/a.PDF"}},
+        {"/docs/b.txt", []string{"xdg-open", "/docs/b.txt"}},
+        {"https://example.org", []string{"firefox", "--new-tab", "https://example.org"}},
+    }
+    for _, c := range cases {
+        got, err := Command(o, c.target)
+        if err != nil {
+            t.Fatalf("Command(%q): %v", c.target, err)
+        }
+        if len(got) != len(c.want) {
+            t.Fatalf("Command(%q) = %q, want %q", c.target, got, c.want)
+        }
+        for i := range got {
+            if got[i] != c.want[i] {
+                t.Errorf("Command(%q) = %q, want %q", c.target, got, c.want)
+            }
+        }
+    }
+    if got, _ := Command(config.Opener{}, "/x.pdf"); got[0] != DefaultCommand() {
+        t.Errorf("expected platform default, got %q", got)
+    }
+}
@@ -0,0 +1,157 @@
+package pdf
+
+import (
+	"strings"
+	"unicode/utf16"
+)
+
+// cmap is a parsed ToUnicode CMap.
+type cmap struct {
+	codeLens []codespace
+	single   map[string]string // code bytes -> text
+	ranges   []bfrange
+}
+
+type codespace struct {
+	lo, hi string
+}
+
+type bfrange struct {
+	lo, hi string
+	dst    string   // UTF-16BE of the first code, incremented across the range
+	list   []string // explicit destinations, if given as an array
+}
+
+func parseCMap(data []byte) *cmap {
+	cm := &cmap{single: map[string]string{}}
+	lx := &lexer{data: data}
+	var operands []object
+	for {
+		obj := lx.object()
+		if _, end := obj.(eof); end {
+			break
+		}
+		kw, ok := obj.(keyword)
+		if !ok {
+			operands = append(operands, obj)
+			continue
+		}
+		switch kw {
+		case "endcodespacerange":
+			for i := 0; i+1 < len(operands); i += 2 {
+				lo, ok1 := operands[i].(string)
+				hi, ok2 := operands[i+1].(string)
+				if ok1 && ok2 && len(lo) == len(hi) {
+					cm.codeLens = append(cm.codeLens, codespace{lo, hi})
+				}
+			}
+		case "endbfchar":
+			for i := 0; i+1 < len(operands); i += 2 {
+				src, ok1 := operands[i].(string)
+				dst, ok2 := operands[i+1].(string)
+				if ok1 && ok2 {
+					cm.single[src] = utf16Text(dst)
+				}
+			}
+		case "endbfrange":
+			for i := 0; i+2 < len(operands); i += 3 {
+				lo, ok1 := operands[i].(string)
+				hi, ok2 := operands[i+1].(string)
+				if !ok1 || !ok2 || len(lo) != len(hi) {
+					continue
+				}
+				br := bfrange{lo: lo, hi: hi}
+				switch d := operands[i+2].(type) {
+				case string:
+					br.dst = d
+				case array:
+					for _, el := range d {
+						s, _ := el.(string)
+						br.list = append(br.list, utf16Text(s))
+					}
+				}
+				cm.ranges = append(cm.ranges, br)
+			}
+		}
+		operands = operands[:0]
+	}
+	return cm
+}
+
+// decode maps s code by code. ok is false if nothing could be mapped.
+func (cm *cmap) decode(s string, twoByte bool) (string, bool) {
+	var b strings.Builder
+	mapped := false
+	for i := 0; i < len(s); {
+		n := cm.codeLen(s[i:], twoByte)
+		code := s[i:min(i+n, len(s))]
+		i += n
+		if t, ok := cm.lookup(code); ok {
+			b.WriteString(t)
+			mapped = true
+		}
+	}
+	return b.String(), mapped
+}
+
+func (cm *cmap) codeLen(s string, twoByte bool) int {
+	for _, cs := range cm.codeLens {
+		n := len(cs.lo)
+		if n <= len(s) && s[:n] >= cs.lo && s[:n] <= cs.hi {
+			return n
+		}
+	}
+	if twoByte {
+		return 2
+	}
+	return 1
+}
+
+func (cm *cmap) lookup(code string) (string, bool) {
+	if t, ok := cm.single[code]; ok {
+		return t, true
+	}
+	for _, br := range cm.ranges {
+		if len(code) != len(br.lo) || code < br.lo || code > br.hi {
+			continue
+		}
+		off := int(codeValue(code) - codeValue(br.lo))
+		if br.list != nil {
+			if off < len(br.list) {
+				return br.list[off], true
+			}
+			return "", false
+		}
+		if len(br.dst) < 2 {
+			return "", false
+		}
+		// increment the last UTF-16 unit of the destination
+		units := utf16Units(br.dst)
+		units[len(units)-1] += uint16(off)
+		return string(utf16.Decode(units)), true
+	}
+	return "", false
+}
+
+func codeValue(s string) uint32 {
+	var v uint32
+	for i := 0; i < len(s); i++ {
+		v = v<<8 | uint32(s[i])
+	}
+	return v
+}
+
+func utf16Units(s string) []uint16 {
+	units := make([]uint16, len(s)/2)
+	for i := range units {
+		units[i] = uint16(s[2*i])<<8 | uint16(s[2*i+1])
+	}
+	return units
+}
+
+func utf16Text(s string) string {
+	if len(s) == 1 {
+		return string(rune(s[0]))
+	}
+	return string(utf16.Decode(utf16Units(s)))
+}
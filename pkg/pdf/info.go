@@ -0,0 +1,119 @@
+package pdf
+
+import (
+	"strings"
+	"time"
+	"unicode/utf16"
+)
+
+// Info holds the document information dictionary.
+type Info struct {
+	Title        string
+	Author       string
+	Subject      string
+	Keywords     string
+	Creator      string
+	Producer     string
+	CreationDate time.Time
+	ModDate      time.Time
+}
+
+// Info returns the document information dictionary. Missing fields, and
+// all fields of a dictionary too damaged to read, are left empty.
+func (r *Reader) Info() (info Info) {
+	defer func() {
+		if recover() != nil {
+			info = Info{}
+		}
+	}()
+	d := r.dictOf(r.trailer["Info"])
+	if d == nil {
+		return info
+	}
+	text := func(key name) string {
+		s, _ := r.resolve(d[key]).(string)
+		return strings.TrimSpace(DecodeTextString(s))
+	}
+	info.Title = text("Title")
+	info.Author = text("Author")
+	info.Subject = text("Subject")
+	info.Keywords = text("Keywords")
+	info.Creator = text("Creator")
+	info.Producer = text("Producer")
+	info.CreationDate, _ = ParseDate(text("CreationDate"))
+	info.ModDate, _ = ParseDate(text("ModDate"))
+	return info
+}
+
+// KeywordList splits the Keywords field on commas or semicolons, or on
+// whitespace if it contains neither.
+func (info Info) KeywordList() []string {
+	sep := func(r rune) bool { return r == ',' || r == ';' }
+	if !strings.ContainsAny(info.Keywords, ",;") {
+		sep = func(r rune) bool { return r == ' ' || r == '\t' || r == '\n' }
+	}
+	var out []string
+	for _, k := range strings.FieldsFunc(info.Keywords, sep) {
+		if k = strings.TrimSpace(k); k != "" {
+			out = append(out, k)
+		}
+	}
+	return out
+}
+
+// ParseDate parses a PDF date such as "D:20240502113000+02'00'". Missing
+// trailing fields default to their minimum; a missing zone means UTC.
+func ParseDate(s string) (time.Time, bool) {
+	s = strings.TrimPrefix(strings.TrimSpace(s), "D:")
+	if len(s) < 4 {
+		return time.Time{}, false
+	}
+	digits := s
+	zone := ""
+	if i := strings.IndexAny(s, "Z+-"); i >= 0 {
+		digits, zone = s[:i], s[i:]
+	}
+	// pad to YYYYMMDDHHmmSS
+	const layout = "20060102150405"
+	defaults := "00000101000000"
+	if len(digits) > len(layout) || !isDigits(digits) {
+		return time.Time{}, false
+	}
+	digits += defaults[len(digits):]
+	t, err := time.Parse(layout, digits)
+	if err != nil {
+		return time.Time{}, false
+	}
+	zone = strings.ReplaceAll(strings.TrimSuffix(zone, "'"), "'", "")
+	if len(zone) == 5 && (zone[0] == '+' || zone[0] == '-') && isDigits(zone[1:]) {
+		hours := int(zone[1]-'0')*10 + int(zone[2]-'0')
+		mins := int(zone[3]-'0')*10 + int(zone[4]-'0')
+		offset := (hours*60 + mins) * 60
+		if zone[0] == '-' {
+			offset = -offset
+		}
+		t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), 0, time.FixedZone("", offset))
+	}
+	return t, true
+}
+
+func isDigits(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// DecodeTextString decodes a PDF text string: UTF-16BE or UTF-8 with a
+// byte order mark, otherwise PDFDocEncoding (treated as cp1252).
+func DecodeTextString(s string) string {
+	switch {
+	case strings.HasPrefix(s, "\xfe\xff"):
+		return string(utf16.Decode(utf16Units(s[2:])))
+	case strings.HasPrefix(s, "\xef\xbb\xbf"):
+		return s[3:]
+	}
+	return latin1(s)
+}
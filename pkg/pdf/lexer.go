@@ -0,0 +1,242 @@
+package pdf
+
+import (
+	"strconv"
+)
+
+// lexer parses PDF objects from a byte slice. Unknown bare words are
+// returned as keywords, which is how content stream operators are read.
+type lexer struct {
+	data []byte
+	pos  int
+}
+
+// eof is returned by object at the end of input.
+type eof struct{}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\r' || c == '\n' || c == '\f' || c == 0
+}
+
+func isDelim(c byte) bool {
+	switch c {
+	case '(', ')', '<', '>', '[', ']', '{', '}', '/', '%':
+		return true
+	}
+	return false
+}
+
+func isHex(c byte) bool {
+	return c >= '0' && c <= '9' || c >= 'a' && c <= 'f' || c >= 'A' && c <= 'F'
+}
+
+func (lx *lexer) skipSpace() {
+	for lx.pos < len(lx.data) {
+		c := lx.data[lx.pos]
+		if isSpace(c) {
+			lx.pos++
+		} else if c == '%' {
+			for lx.pos < len(lx.data) && lx.data[lx.pos] != '\n' && lx.data[lx.pos] != '\r' {
+				lx.pos++
+			}
+		} else {
+			return
+		}
+	}
+}
+
+// object reads the next object. Integers followed by "G R" become references.
+func (lx *lexer) object() object {
+	return lx.objectDepth(0)
+}
+
+func (lx *lexer) objectDepth(depth int) object {
+	lx.skipSpace()
+	if lx.pos >= len(lx.data) || depth > 64 {
+		return eof{}
+	}
+	c := lx.data[lx.pos]
+	switch {
+	case c == '/':
+		return lx.name()
+	case c == '(':
+		return lx.literal()
+	case c == '<' && lx.pos+1 < len(lx.data) && lx.data[lx.pos+1] == '<':
+		lx.pos += 2
+		d := dict{}
+		for {
+			lx.skipSpace()
+			if lx.pos+1 < len(lx.data) && lx.data[lx.pos] == '>' && lx.data[lx.pos+1] == '>' {
+				lx.pos += 2
+				return d
+			}
+			key, ok := lx.objectDepth(depth + 1).(name)
+			if !ok {
+				return d
+			}
+			val := lx.objectDepth(depth + 1)
+			if _, end := val.(eof); end {
+				return d
+			}
+			d[key] = val
+		}
+	case c == '<':
+		return lx.hexString()
+	case c == '[':
+		lx.pos++
+		var a array
+		for {
+			lx.skipSpace()
+			if lx.pos >= len(lx.data) {
+				return a
+			}
+			if lx.data[lx.pos] == ']' {
+				lx.pos++
+				return a
+			}
+			v := lx.objectDepth(depth + 1)
+			if _, end := v.(eof); end {
+				return a
+			}
+			a = append(a, v)
+		}
+	case c == ']' || c == '>' || c == ')' || c == '{' || c == '}':
+		lx.pos++
+		return keyword(string(c))
+	}
+	word := lx.word()
+	if n, err := strconv.Atoi(word); err == nil {
+		// look ahead for "gen R"
+		save := lx.pos
+		lx.skipSpace()
+		gen := lx.word()
+		if g, err := strconv.Atoi(gen); err == nil {
+			lx.skipSpace()
+			if lx.word() == "R" {
+				return objref{n, g}
+			}
+		}
+		lx.pos = save
+		return n
+	}
+	if f, err := strconv.ParseFloat(word, 64); err == nil {
+		return f
+	}
+	switch word {
+	case "true":
+		return true
+	case "false":
+		return false
+	case "null":
+		return nil
+	}
+	return keyword(word)
+}
+
+func (lx *lexer) word() string {
+	start := lx.pos
+	for lx.pos < len(lx.data) && !isSpace(lx.data[lx.pos]) && !isDelim(lx.data[lx.pos]) {
+		lx.pos++
+	}
+	if lx.pos == start && lx.pos < len(lx.data) {
+		lx.pos++ // stray delimiter
+	}
+	return string(lx.data[start:lx.pos])
+}
+
+func (lx *lexer) name() name {
+	lx.pos++ // '/'
+	var b []byte
+	for lx.pos < len(lx.data) {
+		c := lx.data[lx.pos]
+		if isSpace(c) || isDelim(c) {
+			break
+		}
+		if c == '#' && lx.pos+2 < len(lx.data) && isHex(lx.data[lx.pos+1]) && isHex(lx.data[lx.pos+2]) {
+			v, _ := strconv.ParseUint(string(lx.data[lx.pos+1:lx.pos+3]), 16, 8)
+			b = append(b, byte(v))
+			lx.pos += 3
+			continue
+		}
+		b = append(b, c)
+		lx.pos++
+	}
+	return name(b)
+}
+
+func (lx *lexer) literal() string {
+	lx.pos++ // '('
+	var b []byte
+	depth := 1
+	for lx.pos < len(lx.data) {
+		c := lx.data[lx.pos]
+		lx.pos++
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return string(b)
+			}
+		case '\\':
+			if lx.pos >= len(lx.data) {
+				return string(b)
+			}
+			e := lx.data[lx.pos]
+			lx.pos++
+			switch e {
+			case 'n':
+				b = append(b, '\n')
+			case 'r':
+				b = append(b, '\r')
+			case 't':
+				b = append(b, '\t')
+			case 'b':
+				b = append(b, '\b')
+			case 'f':
+				b = append(b, '\f')
+			case '\r':
+				if lx.pos < len(lx.data) && lx.data[lx.pos] == '\n' {
+					lx.pos++
+				}
+			case '\n':
+			default:
+				if e >= '0' && e <= '7' {
+					v := int(e - '0')
+					for k := 0; k < 2 && lx.pos < len(lx.data) && lx.data[lx.pos] >= '0' && lx.data[lx.pos] <= '7'; k++ {
+						v = v*8 + int(lx.data[lx.pos]-'0')
+						lx.pos++
+					}
+					b = append(b, byte(v))
+				} else {
+					b = append(b, e)
+				}
+			}
+			continue
+		}
+		b = append(b, c)
+	}
+	return string(b)
+}
+
+func (lx *lexer) hexString() string {
+	lx.pos++ // '<'
+	var digits []byte
+	for lx.pos < len(lx.data) && lx.data[lx.pos] != '>' {
+		if isHex(lx.data[lx.pos]) {
+			digits = append(digits, lx.data[lx.pos])
+		}
+		lx.pos++
+	}
+	lx.pos++ // '>'
+	if len(digits)%2 == 1 {
+		digits = append(digits, '0')
+	}
+	b := make([]byte, len(digits)/2)
+	for i := range b {
+		v, _ := strconv.ParseUint(string(digits[2*i:2*i+2]), 16, 8)
+		b[i] = byte(v)
+	}
+	return string(b)
+}
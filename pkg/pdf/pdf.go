@@ -0,0 +1,454 @@
+// Package pdf reads the text layer, page count and document information of
+// PDF files without external tools.
+//
+// The reader locates objects by scanning the file rather than trusting the
+// cross-reference table, which makes it tolerant of the damaged or
+// incrementally updated files scanners tend to produce. Compressed object
+// streams are supported; encrypted files are not.
+package pdf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/ascii85"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+)
+
+// ErrEncrypted is returned for password protected or encrypted files.
+var ErrEncrypted = errors.New("pdf: encrypted files are not supported")
+
+// maxStream bounds the decoded size of a single stream.
+const maxStream = 64 << 20
+
+type (
+	object  interface{}
+	name    string
+	keyword string
+	dict    map[name]object
+	array   []object
+	objref  struct{ num, gen int }
+	stream  struct {
+		hdr dict
+		raw []byte
+	}
+)
+
+// Reader gives access to the objects of a PDF file.
+type Reader struct {
+	data    []byte
+	offsets map[int]int       // object number -> offset of "N G obj"
+	packed  map[int]packedObj // objects stored inside object streams
+	cache   map[int]object
+	trailer dict
+}
+
+type packedObj struct {
+	stream int // object number of the /ObjStm
+	index  int
+}
+
+// Open reads and indexes the PDF file at path.
+func Open(path string) (*Reader, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return NewReader(data)
+}
+
+var objHeader = regexp.MustCompile(`(\d+)[ \t\r\n\f\x00]+(\d+)[ \t\r\n\f\x00]+obj\b`)
+
+// NewReader indexes the PDF document in data.
+func NewReader(data []byte) (_ *Reader, err error) {
+	if !bytes.Contains(data[:min(len(data), 1024)], []byte("%PDF-")) {
+		return nil, fmt.Errorf("pdf: not a PDF file")
+	}
+	defer catch(&err)
+	r := &Reader{
+		data:    data,
+		offsets: map[int]int{},
+		packed:  map[int]packedObj{},
+		cache:   map[int]object{},
+		trailer: dict{},
+	}
+	// Later definitions win, as in incremental updates.
+	for _, m := range objHeader.FindAllSubmatchIndex(data, -1) {
+		num, _ := strconv.Atoi(string(data[m[2]:m[3]]))
+		r.offsets[num] = m[0]
+	}
+	r.readTrailers()
+	r.indexObjectStreams()
+	if _, ok := r.trailer["Encrypt"]; ok {
+		return nil, ErrEncrypted
+	}
+	if _, ok := r.trailer["Root"]; !ok {
+		r.findRoot()
+	}
+	return r, nil
+}
+
+// catch turns a panic caused by malformed input into an error. Parsing
+// code checks what it can, but damaged files are too varied to rule out
+// every bad index.
+func catch(err *error) {
+	if v := recover(); v != nil {
+		*err = fmt.Errorf("pdf: malformed file: %v", v)
+	}
+}
+
+// readTrailers merges all classic trailer dictionaries and cross-reference
+// stream dictionaries, later ones overriding earlier ones.
+func (r *Reader) readTrailers() {
+	type found struct {
+		pos int
+		d   dict
+	}
+	var all []found
+	for pos := 0; ; {
+		i := bytes.Index(r.data[pos:], []byte("trailer"))
+		if i < 0 {
+			break
+		}
+		pos += i + len("trailer")
+		lx := &lexer{data: r.data, pos: pos}
+		if d, ok := lx.object().(dict); ok {
+			all = append(all, found{pos, d})
+		}
+	}
+	for num, off := range r.offsets {
+		obj := r.parseAt(off)
+		if s, ok := obj.(stream); ok && s.hdr["Type"] == name("XRef") {
+			all = append(all, found{r.offsets[num], s.hdr})
+		}
+	}
+	// apply in file order
+	sort.Slice(all, func(i, j int) bool { return all[i].pos < all[j].pos })
+	for _, f := range all {
+		for _, k := range []name{"Root", "Info", "Encrypt"} {
+			if v, ok := f.d[k]; ok {
+				r.trailer[k] = v
+			}
+		}
+	}
+}
+
+// indexObjectStreams registers the objects packed into /ObjStm streams.
+func (r *Reader) indexObjectStreams() {
+	for num, off := range r.offsets {
+		s, ok := r.parseAt(off).(stream)
+		if !ok || s.hdr["Type"] != name("ObjStm") {
+			continue
+		}
+		data, err := r.decode(s)
+		if err != nil {
+			continue
+		}
+		n, _ := r.resolve(s.hdr["N"]).(int)
+		lx := &lexer{data: data}
+		for i := 0; i < n; i++ {
+			objNum, ok1 := lx.object().(int)
+			_, ok2 := lx.object().(int)
+			if !ok1 || !ok2 {
+				break
+			}
+			if _, direct := r.offsets[objNum]; !direct {
+				r.packed[objNum] = packedObj{stream: num, index: i}
+			}
+		}
+	}
+}
+
+// findRoot looks for a /Catalog object when no trailer names one.
+func (r *Reader) findRoot() {
+	for num := range r.offsets {
+		if d, ok := r.get(num).(dict); ok && d["Type"] == name("Catalog") {
+			r.trailer["Root"] = objref{num, 0}
+			return
+		}
+	}
+}
+
+// get returns object num, loading it on first use.
+func (r *Reader) get(num int) object {
+	if obj, ok := r.cache[num]; ok {
+		return obj
+	}
+	r.cache[num] = nil // guards against reference cycles
+	var obj object
+	if off, ok := r.offsets[num]; ok {
+		obj = r.parseAt(off)
+	} else if p, ok := r.packed[num]; ok {
+		obj = r.unpack(p)
+	}
+	r.cache[num] = obj
+	return obj
+}
+
+// parseAt parses the "N G obj ... endobj" definition at off.
+func (r *Reader) parseAt(off int) object {
+	lx := &lexer{data: r.data, pos: off}
+	lx.object() // number
+	lx.object() // generation
+	if kw, ok := lx.object().(keyword); !ok || kw != "obj" {
+		return nil
+	}
+	obj := lx.object()
+	hdr, ok := obj.(dict)
+	if !ok {
+		return obj
+	}
+	save := lx.pos
+	if kw, ok := lx.object().(keyword); !ok || kw != "stream" {
+		lx.pos = save
+		return obj
+	}
+	start := lx.pos
+	if start < len(r.data) && r.data[start] == '\r' {
+		start++
+	}
+	if start < len(r.data) && r.data[start] == '\n' {
+		start++
+	}
+	end := -1
+	if n, ok := r.lengthOf(hdr); ok && n <= len(r.data)-start {
+		end = start + n
+		if !bytes.Contains(r.data[end:min(end+32, len(r.data))], []byte("endstream")) {
+			end = -1
+		}
+	}
+	if end < 0 {
+		i := bytes.Index(r.data[start:], []byte("endstream"))
+		if i < 0 {
+			return nil
+		}
+		end = start + i
+		for end > start && (r.data[end-1] == '\n' || r.data[end-1] == '\r') {
+			end--
+		}
+	}
+	return stream{hdr: hdr, raw: r.data[start:end]}
+}
+
+// lengthOf returns /Length without resolving through object streams that
+// may not be indexed yet. Negative lengths are rejected.
+func (r *Reader) lengthOf(hdr dict) (int, bool) {
+	n, ok := hdr["Length"].(int)
+	if ref, isRef := hdr["Length"].(objref); isRef {
+		if off, found := r.offsets[ref.num]; found {
+			lx := &lexer{data: r.data, pos: off}
+			lx.object()
+			lx.object()
+			lx.object()
+			n, ok = lx.object().(int)
+		}
+	}
+	return n, ok && n >= 0
+}
+
+func (r *Reader) unpack(p packedObj) object {
+	s, ok := r.get(p.stream).(stream)
+	if !ok {
+		return nil
+	}
+	data, err := r.decode(s)
+	if err != nil {
+		return nil
+	}
+	n, _ := r.resolve(s.hdr["N"]).(int)
+	first, _ := r.resolve(s.hdr["First"]).(int)
+	if p.index < 0 || p.index >= n {
+		return nil
+	}
+	lx := &lexer{data: data}
+	var off int
+	for i := 0; i <= p.index; i++ {
+		lx.object()
+		off, _ = lx.object().(int)
+	}
+	if first < 0 || off < 0 || off >= len(data)-first {
+		return nil
+	}
+	lx = &lexer{data: data, pos: first + off}
+	return lx.object()
+}
+
+// resolve follows indirect references.
+func (r *Reader) resolve(obj object) object {
+	for i := 0; i < 32; i++ {
+		ref, ok := obj.(objref)
+		if !ok {
+			return obj
+		}
+		obj = r.get(ref.num)
+	}
+	return nil
+}
+
+func (r *Reader) dictOf(obj object) dict {
+	switch v := r.resolve(obj).(type) {
+	case dict:
+		return v
+	case stream:
+		return v.hdr
+	}
+	return nil
+}
+
+// decode applies the stream's filters.
+func (r *Reader) decode(s stream) ([]byte, error) {
+	data := s.raw
+	var filters []object
+	switch f := r.resolve(s.hdr["Filter"]).(type) {
+	case name:
+		filters = []object{f}
+	case array:
+		filters = f
+	}
+	parms := r.resolve(s.hdr["DecodeParms"])
+	for i, f := range filters {
+		var p dict
+		switch v := parms.(type) {
+		case dict:
+			p = v
+		case array:
+			if i < len(v) {
+				p = r.dictOf(v[i])
+			}
+		}
+		var err error
+		switch r.resolve(f) {
+		case name("FlateDecode"), name("Fl"):
+			data, err = inflate(data)
+			if err == nil && p != nil {
+				data, err = unpredict(data, p)
+			}
+		case name("ASCIIHexDecode"), name("AHx"):
+			data, err = asciiHex(data)
+		case name("ASCII85Decode"), name("A85"):
+			data, err = ascii85Decode(data)
+		default:
+			return nil, fmt.Errorf("pdf: unsupported filter %v", f)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return data, nil
+}
+
+func inflate(data []byte) ([]byte, error) {
+	zr, err := zlib.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	out, err := io.ReadAll(io.LimitReader(zr, maxStream))
+	if err != nil && len(out) == 0 {
+		return nil, err
+	}
+	// Truncated streams are common; keep what could be inflated.
+	return out, nil
+}
+
+// unpredict reverses PNG predictors (used mainly by xref streams).
+func unpredict(data []byte, p dict) ([]byte, error) {
+	pred, _ := p["Predictor"].(int)
+	if pred < 10 {
+		return data, nil
+	}
+	cols := 1
+	if c, ok := p["Columns"].(int); ok {
+		cols = c
+	}
+	if cols < 1 {
+		return nil, fmt.Errorf("pdf: bad predictor columns %d", cols)
+	}
+	if cols >= len(data) {
+		return nil, nil
+	}
+	rowLen := cols + 1
+	var out []byte
+	prev := make([]byte, cols)
+	for i := 0; i+rowLen <= len(data); i += rowLen {
+		row := data[i+1 : i+rowLen]
+		cur := make([]byte, cols)
+		for j := range row {
+			var left, up, upLeft byte
+			if j > 0 {
+				left = cur[j-1]
+				upLeft = prev[j-1]
+			}
+			up = prev[j]
+			switch data[i] {
+			case 0:
+				cur[j] = row[j]
+			case 1:
+				cur[j] = row[j] + left
+			case 2:
+				cur[j] = row[j] + up
+			case 3:
+				cur[j] = row[j] + byte((int(left)+int(up))/2)
+			case 4:
+				cur[j] = row[j] + paeth(left, up, upLeft)
+			default:
+				return nil, fmt.Errorf("pdf: bad PNG predictor %d", data[i])
+			}
+		}
+		out = append(out, cur...)
+		prev = cur
+	}
+	return out, nil
+}
+
+func paeth(a, b, c byte) byte {
+	p := int(a) + int(b) - int(c)
+	pa, pb, pc := abs(p-int(a)), abs(p-int(b)), abs(p-int(c))
+	if pa <= pb && pa <= pc {
+		return a
+	} else if pb <= pc {
+		return b
+	}
+	return c
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+func asciiHex(data []byte) ([]byte, error) {
+	var clean []byte
+	for _, c := range data {
+		if c == '>' {
+			break
+		}
+		if isHex(c) {
+			clean = append(clean, c)
+		}
+	}
+	if len(clean)%2 == 1 {
+		clean = append(clean, '0')
+	}
+	out := make([]byte, len(clean)/2)
+	_, err := hex.Decode(out, clean)
+	return out, err
+}
+
+func ascii85Decode(data []byte) ([]byte, error) {
+	data = bytes.TrimPrefix(bytes.TrimSpace(data), []byte("<~"))
+	if i := bytes.Index(data, []byte("~>")); i >= 0 {
+		data = data[:i]
+	}
+	out := make([]byte, 4*len(data)/5+4)
+	n, _, err := ascii85.Decode(out, data, true)
+	return out[:n], err
+}
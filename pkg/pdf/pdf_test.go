@@ -0,0 +1,162 @@
+package pdf
+
+import (
+    "bytes"
+    "compress/zlib"
+    "fmt"
+    "strings"
+    "testing"
+)
+
+// buildPDF assembles a small document from object bodies (object n is
+// objs[n-1]). Stream bodies are given as "dict\x00data" and get compressed.
+func buildPDF(objs []string, trailer string) []byte {
+    var b bytes.Buffer
+    b.WriteString("%PDF-1.5\n")
+    for i, o := range objs {
+        fmt.Fprintf(&b, "%d 0 obj\n", i+1)
+        if d, data, ok := strings.Cut(o, "\x00"); ok {
+            var z bytes.Buffer
+            zw := zlib.NewWriter(&z)
+            zw.Write([]byte(data))
+            zw.Close()
+            fmt.Fprintf(&b, "%s /Filter /FlateDecode /Length %d >>\nstream\n", strings.TrimSuffix(d, ">>"), z.Len())
+            b.Write(z.Bytes())
+            b.WriteString("\nendstream")
+        } else {
+            b.WriteString(o)
+        }
+        b.WriteString("\nendobj\n")
+    }
+    fmt.Fprintf(&b, "trailer\n%s\n%%%%EOF\n", trailer)
+    return b.Bytes()
+}
+
+func TestText(t *testing.T) {
+    cmap := "begincmap\n1 begincodespacerange <0000> <FFFF> endcodespacerange\n" +
+        "2 beginbfchar <0001> <00FC> <0002> <0062> endbfchar\n" +
+        "1 beginbfrange <0010> <0012> <0041> endbfrange\nendcmap"
+    data := buildPDF([]string{
+        "<< /Type /Catalog /Pages 2 0 R >>",
+        "<< /Type /Pages /Kids [3 0 R 4 0 R] /Count 2 /Resources << /Font << /F1 5 0 R /F2 6 0 R >> >> >>",
+        "<< /Type /Page /Parent 2 0 R /Contents 7 0 R >>",
+        "<< /Type /Page /Parent 2 0 R /Contents 8 0 R >>",
+        "<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding << /Differences [65 /adieresis] >> >>",
+        "<< /Type /Font /Subtype /Type0 /BaseFont /X /ToUnicode 9 0 R >>",
+        "<< >>\x00BT /F1 12 Tf 72 720 Td (Hello) Tj 0 -14 Td [(W) 20 (orld) -300 (Ahnlich)] TJ ET",
+        "<< >>\x00BT /F2 12 Tf 1 0 0 1 72 720 Tm <00010002> Tj 1 0 0 1 72 700 Tm <001000110012> Tj ET",
+        "<< >>\x00" + cmap,
+    }, "<< /Root 1 0 R /Size 10 >>")
+
+    r, err := NewReader(data)
+    if err != nil {
+        t.Fatalf("NewReader: %v", err)
+    }
+    if n := r.NumPages(); n != 2 {
+        t.Errorf("NumPages = %d, want 2", n)
+    }
+    want := "Hello\nWorld ähnlich\füb\nABC"
+    if got, err := r.Text(); err != nil || got != want {
+        t.Errorf("Text = %q, %v, want %q", got, err, want)
+    }
+    if _, err := NewReader([]byte("not a pdf")); err == nil {
+        t.Error("expected error for non-PDF input")
+    }
+}
+
+func TestInfo(t *testing.T) {
+    data := buildPDF([]string{
+        "<< /Type /Catalog /Pages 2 0 R >>",
+        "<< /Type /Pages /Kids [] /Count 0 >>",
+        "<< /Title <FEFF0053007400650075006500720020004400FC> /Keywords (steuer, 2023; bescheid) /CreationDate (D:20240502113000+02'00') >>",
+    }, "<< /Root 1 0 R /Info 3 0 R /Size 4 >>")
+    r, err := NewReader(data)
+    if err != nil {
+        t.Fatalf("NewReader: %v", err)
+    }
+    info := r.Info()
+    if info.Title != "Steuer Dü" {
+        t.Errorf("Title = %q", info.Title)
+    }
+    if kw := strings.Join(info.KeywordList(), "|"); kw != "steuer|2023|bescheid" {
+        t.Errorf("KeywordList = %q", kw)
+    }
+    if got := info.CreationDate.UTC().Format("2006-01-02 15:04"); got != "2024-05-02 09:30" {
+        t.Errorf("CreationDate = %s", got)
+    }
+    if _, ok := ParseDate("D:2024"); !ok {
+        t.Error("ParseDate should accept a year only")
+    }
+    if _, ok := ParseDate("yesterday"); ok {
+        t.Error("ParseDate accepted garbage")
+    }
+}
+
+// malformed returns damaged variants of a valid document: truncations,
+// negative lengths and bogus object stream and predictor parameters.
+func malformed() [][]byte {
+    valid := buildPDF([]string{
+        "<< /Type /Catalog /Pages 2 0 R >>",
+        "<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+        "<< /Type /Page /Parent 2 0 R /Contents 4 0 R >>",
+        "<< >>\x00BT /F1 12 Tf (Hello) Tj ET",
+    }, "<< /Root 1 0 R /Size 5 >>")
+    out := [][]byte{
+        []byte("%PDF-1.4\n1 0 obj << /Length -5 >>\nstream\nabc\nendstream\nendobj\n"),
+        []byte("%PDF-1.4\n1 0 obj << /Length 2 0 R >>\nstream\nabc\nendstream\nendobj\n2 0 obj -9223372036854775808 endobj\n"),
+        []byte("%PDF-1.4\n1 0 obj << /Length 9223372036854775807 >>\nstream\nabc\nendstream\nendobj\n"),
+        buildPDF([]string{
+            "<< /Type /ObjStm /N 1 /First -10 >>\x003 0 (x)",
+            "<< /Type /Catalog /Pages 3 0 R >>",
+        }, "<< /Root 2 0 R >>"),
+        buildPDF([]string{
+            "<< /Type /ObjStm /N 1 /First 4 >>\x003 -9 (x)",
+            "<< /Type /Catalog /Pages 3 0 R >>",
+        }, "<< /Root 2 0 R >>"),
+        buildPDF([]string{
+            "<< /Type /XRef /DecodeParms << /Predictor 12 /Columns -3 >> >>\x00abcdef",
+            "<< /Type /XRef /DecodeParms << /Predictor 12 /Columns 0 >> >>\x00abcdef",
+        }, "<< >>"),
+    }
+    for n := len(valid) - 1; n > 8; n -= 7 {
+        out = append(out, valid[:n])
+    }
+    return out
+}
+
+// TestMalformed checks that the known cases are handled by bounds checks,
+// not by the panic recovery in NewReader and Text.
+func TestMalformed(t *testing.T) {
+    for i, data := range malformed() {
+        r, err := NewReader(data)
+        if err != nil {
+            if strings.Contains(err.Error(), "malformed") {
+                t.Errorf("case %d: NewReader: %v", i, err)
+            }
+            continue
+        }
+        r.NumPages()
+        r.Info()
+        if _, err := r.Text(); err != nil {
+            t.Errorf("case %d: Text: %v", i, err)
+        }
+    }
+    if _, err := unpredict([]byte{0, 1, 2}, dict{"Predictor": 12, "Columns": -1}); err == nil {
+        t.Error("unpredict accepted negative columns")
+    }
+}
+
+func FuzzReader(f *testing.F) {
+    for _, data := range malformed() {
+        f.Add(data)
+    }
+    f.Fuzz(func(t *testing.T, data []byte) {
+        r, err := NewReader(data)
+        if err != nil {
+            return
+        }
+        r.NumPages()
+        r.Info()
+        r.Text()
+    })
+}
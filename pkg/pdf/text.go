@@ -0,0 +1,329 @@
+package pdf
+
+import (
+	"strings"
+)
+
+// page is a leaf of the page tree with its inherited resources.
+type page struct {
+	d         dict
+	resources dict
+}
+
+// pages returns the document's pages in order.
+func (r *Reader) pages() []page {
+	root := r.dictOf(r.trailer["Root"])
+	if root == nil {
+		return nil
+	}
+	var out []page
+	seen := map[int]bool{}
+	var walk func(obj object, res dict, depth int)
+	walk = func(obj object, res dict, depth int) {
+		if ref, ok := obj.(objref); ok {
+			if seen[ref.num] {
+				return
+			}
+			seen[ref.num] = true
+		}
+		d := r.dictOf(obj)
+		if d == nil || depth > 64 {
+			return
+		}
+		if rd := r.dictOf(d["Resources"]); rd != nil {
+			res = rd
+		}
+		kids, isNode := r.resolve(d["Kids"]).(array)
+		if !isNode {
+			out = append(out, page{d: d, resources: res})
+			return
+		}
+		for _, k := range kids {
+			walk(k, res, depth+1)
+		}
+	}
+	walk(root["Pages"], nil, 0)
+	return out
+}
+
+// NumPages returns the number of pages in the document; a page tree too
+// damaged to walk counts as no pages.
+func (r *Reader) NumPages() (n int) {
+	defer func() {
+		if recover() != nil {
+			n = 0
+		}
+	}()
+	return len(r.pages())
+}
+
+// Text returns the text layer of all pages. Pages are separated by a form
+// feed; text lines by newlines.
+func (r *Reader) Text() (_ string, err error) {
+	defer catch(&err)
+	var b strings.Builder
+	for i, p := range r.pages() {
+		if i > 0 {
+			b.WriteString("\f")
+		}
+		b.WriteString(r.pageText(p))
+	}
+	return b.String(), nil
+}
+
+func (r *Reader) pageText(p page) string {
+	var content []byte
+	switch c := r.resolve(p.d["Contents"]).(type) {
+	case stream:
+		content, _ = r.decode(c)
+	case array:
+		for _, part := range c {
+			if s, ok := r.resolve(part).(stream); ok {
+				data, err := r.decode(s)
+				if err == nil {
+					content = append(content, data...)
+					content = append(content, '\n')
+				}
+			}
+		}
+	}
+	tw := &textWriter{}
+	r.runContent(content, p.resources, tw, 0)
+	return strings.TrimSpace(tw.b.String())
+}
+
+// textWriter collects text while avoiding duplicate separators.
+type textWriter struct {
+	b    strings.Builder
+	last rune
+}
+
+func (tw *textWriter) text(s string) {
+	for _, c := range s {
+		if c == 0 {
+			continue
+		}
+		tw.b.WriteRune(c)
+		tw.last = c
+	}
+}
+
+func (tw *textWriter) sep(c rune) {
+	if tw.b.Len() == 0 || tw.last == '\n' || (tw.last == ' ' && c == ' ') {
+		return
+	}
+	if tw.last == ' ' && c == '\n' {
+		// upgrade the pending space to a line break
+		s := tw.b.String()
+		tw.b.Reset()
+		tw.b.WriteString(s[:len(s)-1])
+	}
+	tw.b.WriteRune(c)
+	tw.last = c
+}
+
+// runContent interprets the text operators of a content stream.
+func (r *Reader) runContent(content []byte, res dict, tw *textWriter, depth int) {
+	if depth > 8 {
+		return
+	}
+	fonts := r.dictOf(res["Font"])
+	xobjects := r.dictOf(res["XObject"])
+	decoders := map[name]*fontDecoder{}
+	var cur *fontDecoder
+	var lineY float64
+	var operands []object
+
+	lx := &lexer{data: content}
+	for {
+		obj := lx.object()
+		if _, end := obj.(eof); end {
+			break
+		}
+		op, isOp := obj.(keyword)
+		if !isOp {
+			operands = append(operands, obj)
+			continue
+		}
+		switch op {
+		case "BI":
+			// skip inline image data up to "EI"
+			for lx.pos < len(lx.data) {
+				i := strings.Index(string(lx.data[lx.pos:]), "EI")
+				if i < 0 {
+					lx.pos = len(lx.data)
+					break
+				}
+				lx.pos += i + 2
+				if lx.pos >= len(lx.data) || isSpace(lx.data[lx.pos]) {
+					break
+				}
+			}
+		case "ET":
+			tw.sep(' ')
+		case "Tf":
+			if len(operands) >= 1 {
+				if fn, ok := operands[0].(name); ok {
+					if decoders[fn] == nil && fonts != nil {
+						decoders[fn] = r.fontDecoder(fonts[fn])
+					}
+					cur = decoders[fn]
+				}
+			}
+		case "Td", "TD":
+			if len(operands) >= 2 && number(operands[1]) != 0 {
+				tw.sep('\n')
+			} else if len(operands) >= 1 && number(operands[0]) > 0 {
+				tw.sep(' ')
+			}
+		case "Tm":
+			if len(operands) >= 6 {
+				y := number(operands[5])
+				if y != lineY {
+					tw.sep('\n')
+				} else {
+					tw.sep(' ')
+				}
+				lineY = y
+			}
+		case "T*":
+			tw.sep('\n')
+		case "Tj":
+			if len(operands) >= 1 {
+				if s, ok := operands[0].(string); ok {
+					tw.text(cur.decode(s))
+				}
+			}
+		case "'", "\"":
+			tw.sep('\n')
+			if len(operands) >= 1 {
+				if s, ok := operands[len(operands)-1].(string); ok {
+					tw.text(cur.decode(s))
+				}
+			}
+		case "TJ":
+			if len(operands) >= 1 {
+				if a, ok := operands[0].(array); ok {
+					for _, el := range a {
+						switch v := el.(type) {
+						case string:
+							tw.text(cur.decode(v))
+						default:
+							if number(v) < -200 {
+								tw.sep(' ')
+							}
+						}
+					}
+				}
+			}
+		case "Do":
+			if len(operands) >= 1 && xobjects != nil {
+				if xn, ok := operands[0].(name); ok {
+					if s, ok := r.resolve(xobjects[xn]).(stream); ok && s.hdr["Subtype"] == name("Form") {
+						data, err := r.decode(s)
+						if err == nil {
+							formRes := r.dictOf(s.hdr["Resources"])
+							if formRes == nil {
+								formRes = res
+							}
+							r.runContent(data, formRes, tw, depth+1)
+						}
+					}
+				}
+			}
+		}
+		operands = operands[:0]
+	}
+}
+
+func number(obj object) float64 {
+	switch v := obj.(type) {
+	case int:
+		return float64(v)
+	case float64:
+		return v
+	}
+	return 0
+}
+
+// fontDecoder maps string bytes shown with a font to Unicode.
+type fontDecoder struct {
+	cmap    *cmap
+	twoByte bool
+	simple  *[256]rune
+}
+
+func (r *Reader) fontDecoder(obj object) *fontDecoder {
+	font := r.dictOf(obj)
+	if font == nil {
+		return nil
+	}
+	fd := &fontDecoder{}
+	if s, ok := r.resolve(font["ToUnicode"]).(stream); ok {
+		if data, err := r.decode(s); err == nil {
+			fd.cmap = parseCMap(data)
+		}
+	}
+	if font["Subtype"] == name("Type0") {
+		fd.twoByte = true
+		return fd
+	}
+	table := winAnsi
+	switch enc := r.resolve(font["Encoding"]).(type) {
+	case name:
+		if enc == "MacRomanEncoding" {
+			table = macRoman
+		}
+	case dict:
+		if enc["BaseEncoding"] == name("MacRomanEncoding") {
+			table = macRoman
+		}
+		if diffs, ok := r.resolve(enc["Differences"]).(array); ok {
+			code := 0
+			for _, d := range diffs {
+				switch v := d.(type) {
+				case int:
+					code = v
+				case name:
+					if code >= 0 && code < 256 {
+						if c, ok := glyphRune(string(v)); ok {
+							table[code] = c
+						}
+					}
+					code++
+				}
+			}
+		}
+	}
+	fd.simple = &table
+	return fd
+}
+
+// decode converts a shown string to text.
+func (fd *fontDecoder) decode(s string) string {
+	if fd == nil {
+		return latin1(s)
+	}
+	if fd.cmap != nil {
+		if t, ok := fd.cmap.decode(s, fd.twoByte); ok {
+			return t
+		}
+	}
+	if fd.twoByte {
+		// Identity-H without a ToUnicode map: glyph ids carry no text.
+		return ""
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		b.WriteRune(fd.simple[s[i]])
+	}
+	return b.String()
+}
+
+func latin1(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		b.WriteRune(winAnsi[s[i]])
+	}
+	return b.String()
+}
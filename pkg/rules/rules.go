@@ -0,0 +1,212 @@
+// Package rules implements rule-based tagging from a .catrules file.
+//
+// Each non-empty line maps a pattern to tags, separated by "->":
+//
+//	# comment
+//	*Allianz*              -> versicherung
+//	*Lohnsteuer*           -> steuer arbeit
+//	re:^(\d{4})-\d\d-\d\d_ -> jahr-$1
+//	ext:jpg                -> foto
+//	folder:Belege          -> beleg
+//	default                -> neu
+//
+// Plain patterns are case-insensitive filename globs. "re:" patterns are Go
+// regular expressions whose capture groups can be used in tags as $1 or
+// ${name}. "ext:" matches the file extension and "folder:" any folder name
+// in the catalog's path (both case-insensitive). Default tags are given to
+// every new entry.
+package rules
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Filename is the rules file stored next to .cat.
+var Filename = ".catrules"
+
+// Rule maps one pattern to tags.
+type Rule struct {
+	Line    int
+	Pattern string
+	Tags    []string
+	re      *regexp.Regexp
+	kind    string // "glob", "re", "ext" or "folder"
+}
+
+// Set is a parsed .catrules file.
+type Set struct {
+	Rules    []Rule
+	Defaults []string
+}
+
+// Load reads the rules file in dir. A missing file gives an empty set.
+func Load(dir string) (*Set, error) {
+	f, err := os.Open(filepath.Join(dir, Filename))
+	if os.IsNotExist(err) {
+		return &Set{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Parse(f)
+}
+
+// Parse reads rules from r.
+func Parse(r io.Reader) (*Set, error) {
+	s := &Set{}
+	scanner := bufio.NewScanner(r)
+	n := 0
+	for scanner.Scan() {
+		n++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		i := strings.LastIndex(line, "->")
+		if i < 0 {
+			return nil, fmt.Errorf("%s:%d: missing \"->\"", Filename, n)
+		}
+		pattern := strings.TrimSpace(line[:i])
+		tags := strings.Fields(line[i+2:])
+		if pattern == "" || len(tags) == 0 {
+			return nil, fmt.Errorf("%s:%d: need a pattern and at least one tag", Filename, n)
+		}
+		for _, t := range tags {
+			if fieldTag(t) {
+				return nil, fmt.Errorf("%s:%d: tag %q would be read back as a catalog field", Filename, n, t)
+			}
+		}
+		if pattern == "default" {
+			for _, t := range tags {
+				if t = normalize(t); t != "" && !contains(s.Defaults, t) {
+					s.Defaults = append(s.Defaults, t)
+				}
+			}
+			continue
+		}
+		rule := Rule{Line: n, Pattern: pattern, Tags: tags}
+		switch {
+		case strings.HasPrefix(pattern, "re:"):
+			re, err := regexp.Compile(pattern[3:])
+			if err != nil {
+				return nil, fmt.Errorf("%s:%d: %v", Filename, n, err)
+			}
+			rule.kind, rule.re = "re", re
+		case strings.HasPrefix(pattern, "ext:"):
+			rule.kind = "ext"
+			rule.Pattern = "." + strings.TrimPrefix(strings.ToLower(pattern[4:]), ".")
+		case strings.HasPrefix(pattern, "folder:"):
+			rule.kind = "folder"
+			rule.Pattern = strings.ToLower(pattern[7:])
+			if _, err := filepath.Match(rule.Pattern, ""); err != nil {
+				return nil, fmt.Errorf("%s:%d: %v", Filename, n, err)
+			}
+		default:
+			rule.kind = "glob"
+			rule.Pattern = strings.ToLower(pattern)
+			if _, err := filepath.Match(rule.Pattern, ""); err != nil {
+				return nil, fmt.Errorf("%s:%d: %v", Filename, n, err)
+			}
+		}
+		s.Rules = append(s.Rules, rule)
+	}
+	return s, scanner.Err()
+}
+
+// Empty reports whether the set has neither rules nor default tags.
+func (s *Set) Empty() bool {
+	return s == nil || (len(s.Rules) == 0 && len(s.Defaults) == 0)
+}
+
+// Match returns the tags of all rules matching the file name in dir, in
+// rule order and without duplicates. Default tags are not included.
+func (s *Set) Match(dir, name string) []string {
+	if s == nil {
+		return nil
+	}
+	var out []string
+	seen := map[string]bool{}
+	add := func(tag string) {
+		tag = normalize(tag)
+		if tag != "" && !seen[tag] {
+			seen[tag] = true
+			out = append(out, tag)
+		}
+	}
+	lower := strings.ToLower(name)
+	for _, r := range s.Rules {
+		switch r.kind {
+		case "glob":
+			if ok, _ := filepath.Match(r.Pattern, lower); ok {
+				for _, t := range r.Tags {
+					add(t)
+				}
+			}
+		case "ext":
+			if strings.ToLower(filepath.Ext(name)) == r.Pattern {
+				for _, t := range r.Tags {
+					add(t)
+				}
+			}
+		case "folder":
+			if inFolder(dir, r.Pattern) {
+				for _, t := range r.Tags {
+					add(t)
+				}
+			}
+		case "re":
+			m := r.re.FindStringSubmatchIndex(name)
+			if m == nil {
+				continue
+			}
+			for _, t := range r.Tags {
+				add(string(r.re.ExpandString(nil, t, name, m)))
+			}
+		}
+	}
+	return out
+}
+
+// normalize makes a rule tag safe for a .cat line, where '*' separates
+// fields. Expanded tags that look like a field are dropped.
+func normalize(tag string) string {
+	tag = strings.TrimSpace(strings.ReplaceAll(tag, "*", ""))
+	if fieldTag(tag) {
+		return ""
+	}
+	return tag
+}
+
+// fieldTag reports whether tag starts like the "@title=" and "@note="
+// fields of a .cat line (see catalog.CheckTag, which this package cannot
+// import).
+func fieldTag(tag string) bool {
+	return strings.HasPrefix(tag, "@title=") || strings.HasPrefix(tag, "@note=")
+}
+
+func contains(tags []string, tag string) bool {
+	for _, t := range tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// inFolder reports whether any folder name of dir matches the lower-case
+// glob pattern.
+func inFolder(dir, pattern string) bool {
+	for _, part := range strings.Split(filepath.ToSlash(filepath.Clean(dir)), "/") {
+		if ok, _ := filepath.Match(pattern, strings.ToLower(part)); ok && part != "" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,43 @@
+package rules
+
+import (
+    "strings"
+    "testing"
+)
+
+func TestMatch(t *testing.T) {
+    src := "# tax stuff\n" +
+        "*Allianz*  -> versicherung\n" +
+        "*lohnsteuer* -> steuer arbeit\n" +
+        "re:^(?P<year>\\d{4})-\\d\\d -> jahr-${year}\n" +
+        "ext:PDF -> pdf\n" +
+        "folder:belege -> beleg\n" +
+        "default -> neu *inbox* neu\n"
+    s, err := Parse(strings.NewReader(src))
+    if err != nil {
+        t.Fatalf("Parse: %v", err)
+    }
+    if len(s.Rules) != 5 || strings.Join(s.Defaults, ",") != "neu,inbox" {
+        t.Fatalf("unexpected set: %+v", s)
+    }
+    got := strings.Join(s.Match("/home/x/Belege", "2023-04_Lohnsteuer_Allianz.pdf"), ",")
+    if want := "versicherung,steuer,arbeit,jahr-2023,pdf,beleg"; got != want {
+        t.Errorf("Match = %q, want %q", got, want)
+    }
+    if got := s.Match("/home/x/docs", "notes.txt"); len(got) != 0 {
+        t.Errorf("unexpected tags %v", got)
+    }
+    if _, err := Parse(strings.NewReader("re:( -> x\n")); err == nil {
+        t.Error("expected error for bad regexp")
+    }
+    if _, err := Parse(strings.NewReader("*.pdf pdf\n")); err == nil {
+        t.Error("expected error for missing arrow")
+    }
+    if _, err := Parse(strings.NewReader("*.pdf -> @title=x\n")); err == nil {
+        t.Error("expected error for a tag that looks like a field")
+    }
+    s, err = Parse(strings.NewReader("re:^(.*)\\.txt$ -> $1\n"))
+    if err != nil || len(s.Match("/x", "@note=a.txt")) != 0 {
+        t.Errorf("expanded field tag kept: %v", err)
+    }
+}
@@ -0,0 +1,144 @@
+// Package shell is the filemac shell: the registry of commands with their
+// argument specs and help, splitting input into commands and words,
+// expanding user aliases and running scripts.
+package shell
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/tenzokai/filemac/pkg/utils"
+)
+
+// Split breaks a command line into words; quoting follows
+// utils.SplitWords, which the interactive search loop shares.
+func Split(line string) ([]string, error) {
+	return utils.SplitWords(line)
+}
+
+// SplitCommands breaks a line into the commands separated by ';' outside
+// quotes. Empty commands are dropped.
+func SplitCommands(line string) []string {
+	var out []string
+	var quote rune
+	escaped := false
+	start := 0
+	for i, r := range line {
+		switch {
+		case escaped:
+			escaped = false
+		case r == '\\' && quote != '\'':
+			escaped = true
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '\'' || r == '"':
+			quote = r
+		case r == ';':
+			out = append(out, line[start:i])
+			start = i + 1
+		}
+	}
+	out = append(out, line[start:])
+	cmds := out[:0]
+	for _, c := range out {
+		if strings.TrimSpace(c) != "" {
+			cmds = append(cmds, c)
+		}
+	}
+	return cmds
+}
+
+// Expander replaces alias names at the start of a command with the commands
+// they stand for. An alias holding several commands separated by ';' is a
+// macro. "$1" to "$9" are replaced with the arguments and "$@" with all of
+// them; a single-command alias that uses none gets the arguments appended.
+// Aliases may use other aliases; an alias is not expanded inside itself, so
+// vc = "vc -l" works.
+type Expander struct {
+	Aliases map[string]string
+}
+
+// Expand returns the commands args stands for; args itself if it does not
+// start with an alias.
+func (x *Expander) Expand(args []string) ([][]string, error) {
+	return x.expand(args, map[string]bool{})
+}
+
+func (x *Expander) expand(args []string, active map[string]bool) ([][]string, error) {
+	if len(args) == 0 {
+		return nil, nil
+	}
+	a, ok := x.Aliases[args[0]]
+	if !ok || active[args[0]] {
+		return [][]string{args}, nil
+	}
+	active[args[0]] = true
+	defer delete(active, args[0])
+	lines := SplitCommands(a)
+	appendArgs := len(lines) == 1 && !strings.Contains(a, "$")
+	var out [][]string
+	for _, line := range lines {
+		words, err := Split(line)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", args[0], err)
+		}
+		words, err = substitute(words, args[1:])
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", args[0], err)
+		}
+		if appendArgs {
+			words = append(words, args[1:]...)
+		}
+		cmds, err := x.expand(words, active)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, cmds...)
+	}
+	return out, nil
+}
+
+// substitute replaces $1..$9 and $@ in words with params.
+func substitute(words, params []string) ([]string, error) {
+	var out []string
+	for _, w := range words {
+		if w == "$@" {
+			out = append(out, params...)
+			continue
+		}
+		var b strings.Builder
+		for i := 0; i < len(w); i++ {
+			if w[i] != '$' || i+1 == len(w) || w[i+1] < '1' || w[i+1] > '9' {
+				b.WriteByte(w[i])
+				continue
+			}
+			n, _ := strconv.Atoi(w[i+1 : i+2])
+			if n > len(params) {
+				return nil, fmt.Errorf("needs at least %d arguments", n)
+			}
+			b.WriteString(params[n-1])
+			i++
+		}
+		out = append(out, b.String())
+	}
+	return out, nil
+}
+
+// Describe lists the aliases with their expansions, sorted by name, for
+// help.
+func (x *Expander) Describe() []string {
+	names := make([]string, 0, len(x.Aliases))
+	for name := range x.Aliases {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var out []string
+	for _, name := range names {
+		out = append(out, fmt.Sprintf("%-10s = %s", name, x.Aliases[name]))
+	}
+	return out
+}
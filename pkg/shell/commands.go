@@ -0,0 +1,205 @@
+package shell
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/tenzokai/filemac/pkg/autotag"
+	"github.com/tenzokai/filemac/pkg/catalog"
+	"github.com/tenzokai/filemac/pkg/classify"
+	"github.com/tenzokai/filemac/pkg/config"
+	"github.com/tenzokai/filemac/pkg/csvio"
+	"github.com/tenzokai/filemac/pkg/index"
+	"github.com/tenzokai/filemac/pkg/intake"
+	"github.com/tenzokai/filemac/pkg/tags"
+	"github.com/tenzokai/filemac/pkg/utils"
+	"github.com/tenzokai/filemac/pkg/walkthrough"
+	"github.com/tenzokai/filemac/pkg/watch"
+	"github.com/tenzokai/filemac/pkg/xattr"
+)
+
+// writing adapts a command function that writes to w and returns its errors.
+func (s *Shell) writing(f func(w io.Writer, args []string) error) func([]string) error {
+	return func(args []string) error {
+		return f(s.out(), args)
+	}
+}
+
+// registerBuiltins adds the filemac commands to the shell's registry.
+func (s *Shell) registerBuiltins() {
+	r := s.Registry
+	for _, c := range []Command{
+		{Name: "i", Aliases: []string{"init"}, Args: "[-n]", Group: "Catalog",
+			Help: "sync .cat with the folder (-n: dry run)",
+			Run:  func(a []string) error { return catalog.CmdInitCatalog(s.out(), a...) }},
+		{Name: "vc", Args: "[options...]", Group: "Catalog",
+			Help: "view catalog (-new -l -missing -tag -ext -since -sort)",
+			Run:  func(a []string) error { return catalog.CmdViewCat(s.out(), a...) }},
+		{Name: "o", Args: "<num>", Group: "Catalog",
+			Help: "open entry",
+			Run:  func(a []string) error { return catalog.CmdOpen(s.out(), a[0]) }},
+		{Name: "vl", Group: "Catalog",
+			Help: "view .catlink",
+			Run:  func([]string) error { return catalog.CmdViewLinkcat(s.out()) }},
+		{Name: "link", Args: "<path...>", Group: "Catalog",
+			Help: "write .catlink",
+			Run:  s.writing(catalog.CmdLink)},
+		{Name: "lt", Args: "[-c]", Group: "Catalog",
+			Help: "list tags (with counts)",
+			Run:  func(a []string) error { return tags.CmdListTags(s.out(), a...) }},
+		{Name: "ls", Group: "Catalog",
+			Help: "list folder",
+			Run:  func([]string) error { return utils.CmdLs(s.out()) }},
+		{Name: "cd", Args: "<path>", Group: "Catalog",
+			Help: "change folder",
+			Run:  func(a []string) error { return utils.CmdCd(s.out(), utils.ExpandHome(a[0])) }},
+		{Name: "watch", Args: "[on|off|status]", Group: "Catalog",
+			Help: "keep catalogs in sync",
+			Run:  s.writing(watch.CmdWatch)},
+		{Name: "check", Args: "[--fix]", Group: "Catalog",
+			Help: "find and repair catalog problems",
+			Run:  s.writing(catalog.CmdCheck)},
+		{Name: "config", Group: "Catalog",
+			Help: "show the effective configuration",
+			Run:  func([]string) error { return config.CmdConfig(s.out()) }},
+
+		{Name: "a", Args: "<num> <tag>", Group: "Tags",
+			Help: "add tag to entry",
+			Run:  func(a []string) error { return tags.CmdAddTag(s.out(), a[0], a[1]) }},
+		{Name: "ax", Args: "<tag>", Group: "Tags",
+			Help: "add tag to all entries",
+			Run:  func(a []string) error { return tags.CmdAddTagAll(s.out(), a[0]) }},
+		{Name: "d", Args: "<num> <tag>", Group: "Tags",
+			Help: "remove tag from entry",
+			Run:  func(a []string) error { return tags.CmdRemoveTag(s.out(), a[0], a[1]) }},
+		{Name: "dx", Args: "<tag>", Group: "Tags",
+			Help: "remove tag from all entries",
+			Run:  func(a []string) error { return tags.CmdRemoveTagAll(s.out(), a[0]) }},
+		{Name: "r", Args: "<num> <t1> <t2>", Group: "Tags",
+			Help: "replace tag in entry",
+			Run:  func(a []string) error { return tags.CmdReplaceTag(s.out(), a[0], a[1], a[2]) }},
+		{Name: "rx", Args: "<t1> <t2>", Group: "Tags",
+			Help: "replace tag in all entries",
+			Run:  func(a []string) error { return tags.CmdReplaceTagAll(s.out(), a[0], a[1]) }},
+		{Name: "note", Args: "<num> [text...]", Group: "Tags",
+			Help: "show or set a note",
+			Run:  func(a []string) error { return catalog.CmdNote(s.out(), a[0], a[1:]) }},
+		{Name: "w", Args: "[<num>|-new|-resume|query...]", Group: "Tags",
+			Help: "walk through entries",
+			Run:  s.writing(walkthrough.CmdWalkthrough)},
+		{Name: "autotag", Args: "[--dry-run] [--min-confidence <p>]", Group: "Tags",
+			Help: "tag from rules and model",
+			Run:  s.writing(autotag.CmdAutotag)},
+		{Name: "suggest", Args: "<num>", Group: "Tags",
+			Help: "suggest tags for an entry",
+			Run:  func(a []string) error { return classify.CmdSuggest(s.out(), a[0]) }},
+		{Name: "pdfkw", Args: "[<num>] [-n]", Group: "Tags",
+			Help: "tag from PDF keywords",
+			Run:  s.writing(catalog.CmdPDFKeywords)},
+
+		{Name: "mv", Args: "<num> <newname...>", Group: "Files",
+			Help: "rename a file keeping its tags",
+			Run:  func(a []string) error { return catalog.CmdMove(s.out(), a[0], a[1:]) }},
+		{Name: "ren", Group: "Files",
+			Help: "rename files in an editor",
+			Run:  func([]string) error { return catalog.CmdRenameEditor(s.out()) }},
+		{Name: "rm", Args: "<num|from-to|list...>", Group: "Files",
+			Help: "move entries to the trash",
+			Run:  s.writing(catalog.CmdRemove)},
+		{Name: "trash", Args: "[ls|purge --older <age>]", Group: "Files",
+			Help: "list or empty the trash",
+			Run:  s.writing(catalog.CmdTrash)},
+		{Name: "restore", Args: "<num...>", Group: "Files",
+			Help: "restore entries from the trash",
+			Run:  s.writing(catalog.CmdRestore)},
+		{Name: "au", Args: "<url> [tag...] [-t title...]", Group: "Files",
+			Help: "add a URL entry",
+			Run:  s.writing(catalog.CmdAddURL)},
+		{Name: "intake", Args: "<inbox> <archive>", Group: "Files",
+			Help: "file new documents",
+			Run:  s.writing(intake.CmdIntake)},
+
+		{Name: "s", Args: "<tag...> [-f tmpl]", Group: "Search",
+			Help: "search by tags",
+			Run:  s.writing(tags.CmdSearch)},
+		{Name: "sl", Group: "Search",
+			Help: "search loop",
+			Run:  func([]string) error { return tags.CmdSearchLoop(s.out()) }},
+		{Name: "index", Group: "Search",
+			Help: "build the full-text index",
+			Run:  func([]string) error { return index.CmdIndex(s.out()) }},
+		{Name: "sf", Args: "<word...>", Group: "Search",
+			Help: "full-text search",
+			Run:  s.writing(index.CmdSearchText)},
+		{Name: "export", Args: "csv [-o <file>|file.csv|-] [tag...]", Group: "Search",
+			Help: "export entries as CSV",
+			Run:  s.writing(csvio.CmdExport)},
+		{Name: "import", Args: "csv [-n] <file>", Group: "Search",
+			Help: "import tags from CSV",
+			Run:  s.writing(csvio.CmdImport)},
+		{Name: "xattr", Args: "sync [push|pull|merge]", Group: "Search",
+			Help: "sync tags with Finder tags",
+			Run:  s.writing(xattr.CmdXattr)},
+
+		{Name: "alias", Args: "[<name> = <commands...>]", Group: "Shell",
+			Help: "list or define aliases (';' separates commands)",
+			Run:  func(a []string) error { return s.alias(strings.Join(a, " ")) }},
+		{Name: "unalias", Args: "<name>", Group: "Shell",
+			Help: "remove an alias",
+			Run:  func(a []string) error { return config.SetAlias(a[0], "") }},
+		{Name: "set", Args: "-e|+e|-x|+x", Group: "Shell",
+			Help: "stop at errors / echo commands (scripts)",
+			Run: func([]string) error {
+				return errors.New("set only works in command lines and scripts")
+			}},
+		{Name: "help", Aliases: []string{"h", "?"}, Args: "[command]", Group: "Shell",
+			Help: "show commands and aliases, or the usage of one command",
+			Run:  s.help},
+		{Name: "quit", Aliases: []string{"exit", "q"}, Group: "Shell",
+			Help: "leave the shell",
+			Run:  func([]string) error { return ErrQuit }},
+	} {
+		r.Register(c)
+	}
+}
+
+// help lists the commands and aliases, or shows the usage of one command.
+func (s *Shell) help(args []string) error {
+	if len(args) == 1 {
+		return s.Registry.Help(s.out(), args[0])
+	}
+	s.Registry.Help(s.out(), "")
+	if list := s.expander().Describe(); len(list) > 0 {
+		fmt.Fprintln(s.out(), "Aliases:")
+		for _, a := range list {
+			fmt.Fprintln(s.out(), "  "+a)
+		}
+	}
+	return nil
+}
+
+// alias lists the aliases or defines one: alias <name> = <commands>.
+func (s *Shell) alias(rest string) error {
+	if rest == "" {
+		list := s.expander().Describe()
+		if len(list) == 0 {
+			fmt.Fprintln(s.out(), "(no aliases)")
+		}
+		for _, a := range list {
+			fmt.Fprintln(s.out(), a)
+		}
+		return nil
+	}
+	name, value, ok := strings.Cut(rest, "=")
+	name, value = strings.TrimSpace(name), strings.TrimSpace(value)
+	if !ok || value == "" {
+		return fmt.Errorf("usage: alias <name> = <command>[; <command>...]")
+	}
+	if err := config.SetAlias(name, value); err != nil {
+		return err
+	}
+	fmt.Fprintf(s.out(), "alias %s = %s (saved in %s)\n", name, value, config.Path())
+	return nil
+}
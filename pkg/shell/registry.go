@@ -0,0 +1,165 @@
+package shell
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Command describes one shell command.
+type Command struct {
+	Name    string
+	Aliases []string // other names, e.g. "init" for "i"
+	// Args is the argument spec shown in usage lines and used to check the
+	// number of arguments: <x> is required, [x] optional (its words count
+	// towards the maximum), bare words are required literals and "..."
+	// allows any number of further arguments.
+	Args  string
+	Group string // help section
+	Help  string
+	Run   func(args []string) error
+}
+
+// Usage returns the usage line of c.
+func (c *Command) Usage() string {
+	return strings.TrimSpace(c.Name + " " + c.Args)
+}
+
+// arity returns the minimum and maximum number of arguments the spec
+// allows; max is -1 when unlimited.
+func (c *Command) arity() (min, max int) {
+	for _, tok := range specTokens(c.Args) {
+		words := 1
+		if strings.HasPrefix(tok, "[") {
+			// an optional group: its words only raise the maximum
+			words = len(specTokens(tok[1 : len(tok)-1]))
+		} else {
+			min++
+		}
+		if strings.Contains(tok, "...") {
+			max = -1
+		}
+		if max >= 0 {
+			max += words
+		}
+	}
+	return min, max
+}
+
+// specTokens splits an argument spec at blanks outside brackets.
+func specTokens(spec string) []string {
+	var out []string
+	depth, start := 0, -1
+	for i, r := range spec {
+		switch {
+		case r == '[' || r == '<':
+			depth++
+		case r == ']' || r == '>':
+			depth--
+		}
+		if r == ' ' && depth == 0 {
+			if start >= 0 {
+				out = append(out, spec[start:i])
+				start = -1
+			}
+			continue
+		}
+		if start < 0 {
+			start = i
+		}
+	}
+	if start >= 0 {
+		out = append(out, spec[start:])
+	}
+	return out
+}
+
+// Registry holds the shell commands by name.
+type Registry struct {
+	commands []*Command
+	byName   map[string]*Command
+}
+
+// NewRegistry returns an empty registry.
+func NewRegistry() *Registry {
+	return &Registry{byName: map[string]*Command{}}
+}
+
+// Register adds a command. Names and aliases must be unique.
+func (r *Registry) Register(c Command) {
+	cmd := &c
+	for _, name := range append([]string{c.Name}, c.Aliases...) {
+		if _, dup := r.byName[name]; dup {
+			panic("shell: command registered twice: " + name)
+		}
+		r.byName[name] = cmd
+	}
+	r.commands = append(r.commands, cmd)
+}
+
+// Lookup returns the command called name, or nil.
+func (r *Registry) Lookup(name string) *Command {
+	return r.byName[name]
+}
+
+// Commands returns the commands in registration order.
+func (r *Registry) Commands() []*Command {
+	return r.commands
+}
+
+// Exec checks the arguments of a command and runs it.
+func (r *Registry) Exec(args []string) error {
+	if len(args) == 0 {
+		return nil
+	}
+	c := r.byName[args[0]]
+	if c == nil {
+		return fmt.Errorf("unknown command: %s (try 'help')", args[0])
+	}
+	min, max := c.arity()
+	if n := len(args) - 1; n < min || (max >= 0 && n > max) {
+		return fmt.Errorf("usage: %s", c.Usage())
+	}
+	return c.Run(args[1:])
+}
+
+// Help writes the command list grouped by section, or the usage of one
+// command if name is given.
+func (r *Registry) Help(w io.Writer, name string) error {
+	if name != "" {
+		c := r.byName[name]
+		if c == nil {
+			return fmt.Errorf("unknown command: %s", name)
+		}
+		fmt.Fprintf(w, "usage: %s\n  %s\n", c.Usage(), c.Help)
+		if len(c.Aliases) > 0 {
+			fmt.Fprintf(w, "  also: %s\n", strings.Join(c.Aliases, ", "))
+		}
+		return nil
+	}
+	var groups []string
+	byGroup := map[string][]*Command{}
+	width := 0
+	for _, c := range r.commands {
+		if _, ok := byGroup[c.Group]; !ok {
+			groups = append(groups, c.Group)
+		}
+		byGroup[c.Group] = append(byGroup[c.Group], c)
+		if n := len(helpName(c)); n > width && n <= 32 {
+			width = n
+		}
+	}
+	for _, g := range groups {
+		fmt.Fprintf(w, "%s:\n", g)
+		for _, c := range byGroup[g] {
+			fmt.Fprintf(w, "  %-*s  %s\n", width, helpName(c), c.Help)
+		}
+	}
+	return nil
+}
+
+// helpName is the first help column: names and argument spec.
+func helpName(c *Command) string {
+	names := append([]string{c.Name}, c.Aliases...)
+	return strings.TrimSpace(strings.Join(names, ", ") + " " + c.Args)
+}
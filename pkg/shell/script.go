@@ -0,0 +1,156 @@
+package shell
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// ErrQuit is returned by an Exec function to end the shell or script.
+var ErrQuit = errors.New("quit")
+
+// Runner runs command lines and scripts. Lines may hold several commands
+// separated by ';' and end in a '#' comment. "set -e" stops at the first
+// failing command, "set -x" echoes each command before it runs; "+e" and
+// "+x" turn them off again.
+type Runner struct {
+	// Exec runs one command. An alias definition is passed as "alias" and
+	// the unsplit rest of the line, so it may contain ';'.
+	Exec func(args []string) error
+	Out  io.Writer // for echo and errors; nil means stdout
+
+	StopOnError bool // set -e
+	Echo        bool // set -x
+	Failed      int  // number of commands that failed
+}
+
+func (r *Runner) out() io.Writer {
+	if r.Out == nil {
+		return os.Stdout
+	}
+	return r.Out
+}
+
+// RunLine runs the commands of one line. It returns ErrQuit when a command
+// asks to quit and, with set -e, the error of a failing command.
+func (r *Runner) RunLine(line string) error {
+	line = strings.TrimSpace(StripComment(line))
+	if line == "" {
+		return nil
+	}
+	if rest, ok := strings.CutPrefix(line, "alias"); ok && (rest == "" || rest[0] == ' ' || rest[0] == '\t') {
+		return r.exec([]string{"alias", strings.TrimSpace(rest)})
+	}
+	for _, cmd := range SplitCommands(line) {
+		args, err := Split(cmd)
+		if err == nil && len(args) > 0 && args[0] == "set" {
+			err = r.set(args[1:])
+			if err == nil {
+				continue
+			}
+		}
+		if err != nil {
+			r.Failed++
+			fmt.Fprintln(r.out(), "error:", err)
+			if r.StopOnError {
+				return err
+			}
+			continue
+		}
+		if err := r.exec(args); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// exec runs one command, echoing and counting failures.
+func (r *Runner) exec(args []string) error {
+	if len(args) == 0 {
+		return nil
+	}
+	if r.Echo {
+		fmt.Fprintln(r.out(), "+ "+strings.Join(args, " "))
+	}
+	err := r.Exec(args)
+	if err == nil || err == ErrQuit {
+		return err
+	}
+	r.Failed++
+	fmt.Fprintln(r.out(), "error:", err)
+	if r.StopOnError {
+		return err
+	}
+	return nil
+}
+
+// set handles set -e, set +e, set -x, set +x and combinations like -ex.
+func (r *Runner) set(flags []string) error {
+	if len(flags) == 0 {
+		return fmt.Errorf("usage: set -e|+e|-x|+x")
+	}
+	for _, f := range flags {
+		if len(f) < 2 || (f[0] != '-' && f[0] != '+') {
+			return fmt.Errorf("set: unknown option %s", f)
+		}
+		on := f[0] == '-'
+		for _, c := range f[1:] {
+			switch c {
+			case 'e':
+				r.StopOnError = on
+			case 'x':
+				r.Echo = on
+			default:
+				return fmt.Errorf("set: unknown option %c%c", f[0], c)
+			}
+		}
+	}
+	return nil
+}
+
+// Run runs a script line by line. It stops at quit and, with set -e, at the
+// first failing command, whose error it returns with the line number.
+func (r *Runner) Run(src io.Reader) error {
+	scanner := bufio.NewScanner(src)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		err := r.RunLine(scanner.Text())
+		if err == ErrQuit {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("line %d: %v", lineNo, err)
+		}
+	}
+	return scanner.Err()
+}
+
+// StripComment removes a '#' comment that starts a line or follows a blank,
+// outside quotes.
+func StripComment(line string) string {
+	var quote rune
+	escaped := false
+	prev := ' '
+	for i, r := range line {
+		switch {
+		case escaped:
+			escaped = false
+		case r == '\\' && quote != '\'':
+			escaped = true
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '\'' || r == '"':
+			quote = r
+		case r == '#' && (prev == ' ' || prev == '\t'):
+			return line[:i]
+		}
+		prev = r
+	}
+	return line
+}
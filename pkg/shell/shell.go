@@ -0,0 +1,150 @@
+package shell
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/peterh/liner"
+	"github.com/tenzokai/filemac/pkg/config"
+)
+
+// Shell is the filemac shell: the command registry with the builtin
+// commands, user aliases from the config and the script runner.
+type Shell struct {
+	Registry *Registry
+	Runner   *Runner
+	Out      io.Writer // nil means stdout
+}
+
+// New returns a shell with the builtin commands registered.
+func New() *Shell {
+	s := &Shell{Registry: NewRegistry()}
+	s.Runner = &Runner{Exec: s.Execute}
+	s.registerBuiltins()
+	return s
+}
+
+func (s *Shell) out() io.Writer {
+	if s.Out == nil {
+		return os.Stdout
+	}
+	return s.Out
+}
+
+// expander returns an alias expander for the aliases in the config.
+func (s *Shell) expander() *Expander {
+	cfg, err := config.Load()
+	if err != nil {
+		fmt.Fprintln(s.out(), "config error:", err)
+	}
+	return &Expander{Aliases: cfg.Aliases}
+}
+
+// Execute expands aliases in args and runs the resulting commands.
+func (s *Shell) Execute(args []string) error {
+	if len(args) == 0 {
+		return nil
+	}
+	if args[0] == "alias" {
+		return s.Registry.Exec(args)
+	}
+	cmds, err := s.expander().Expand(args)
+	if err != nil {
+		return fmt.Errorf("alias %v", err)
+	}
+	for _, cmd := range cmds {
+		if len(cmds) > 1 {
+			fmt.Fprintln(s.out(), "> "+strings.Join(cmd, " "))
+		}
+		if err := s.Registry.Exec(cmd); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Main runs the shell for the command line arguments and returns the exit
+// status: interactive without arguments, "-c <line>" for a command line,
+// "run <file>" for a script and otherwise one command.
+func (s *Shell) Main(args []string) int {
+	r := s.Runner
+	if r.Out == nil {
+		r.Out = s.Out
+	}
+	var err error
+	switch {
+	case len(args) == 0:
+		s.Interactive()
+	case args[0] == "-c":
+		if len(args) != 2 {
+			fmt.Fprintln(s.out(), "Usage: filemac -c '<command>[; <command>...]'")
+			return 2
+		}
+		err = r.RunLine(args[1])
+	case args[0] == "run":
+		if len(args) != 2 {
+			fmt.Fprintln(s.out(), "Usage: filemac run <script>")
+			return 2
+		}
+		var f *os.File
+		if f, err = os.Open(args[1]); err == nil {
+			err = r.Run(f)
+			f.Close()
+		}
+	case args[0] == "alias":
+		err = r.RunLine("alias " + strings.Join(args[1:], " "))
+	default:
+		err = s.Execute(args)
+		if err != nil && err != ErrQuit {
+			fmt.Fprintln(s.out(), "error:", err)
+		}
+	}
+	if err == ErrQuit {
+		err = nil
+	}
+	if err != nil && args[0] == "run" {
+		fmt.Fprintln(s.out(), "stopped at", err)
+	}
+	if err != nil || r.Failed > 0 {
+		return 1
+	}
+	return 0
+}
+
+// Interactive runs the shell loop with line editing and history.
+func (s *Shell) Interactive() {
+	line := liner.NewLiner()
+	defer line.Close()
+	line.SetCtrlCAborts(true)
+	for {
+		input, err := line.Prompt(prompt())
+		if err != nil {
+			fmt.Fprintln(s.out())
+			return
+		}
+		input = strings.TrimSpace(input)
+		if input == "" {
+			continue
+		}
+		line.AppendHistory(input)
+		if s.Runner.RunLine(input) == ErrQuit {
+			return
+		}
+	}
+}
+
+// prompt shows the current folder with ~ for the home directory.
+func prompt() string {
+	cwd, _ := os.Getwd()
+	if home, err := os.UserHomeDir(); err == nil && home != "" {
+		if cwd == home {
+			cwd = "~"
+		} else if strings.HasPrefix(cwd, home+string(filepath.Separator)) {
+			cwd = "~" + cwd[len(home):]
+		}
+	}
+	return fmt.Sprintf("filemac [%s]> ", cwd)
+}
@@ -0,0 +1,181 @@
+package shell
+
+import (
+    "bytes"
+    "errors"
+    "os"
+    "reflect"
+    "strings"
+    "testing"
+
+    "github.com/tenzokai/filemac/pkg/catalog"
+)
+
+func TestSplit(t *testing.T) {
+    words, err := Split(`s steuer -f '{{.Path}}\t{{join .Tags ","}}' "two words" a\ b`)
+    want := []string{"s", "steuer", "-f", `{{.Path}}\t{{join .Tags ","}}`, "two words", "a b"}
+    if err != nil || !reflect.DeepEqual(words, want) {
+        t.Errorf("Split = %q, %v", words, err)
+    }
+    if _, err := Split(`a "open`); err == nil {
+        t.Error("unterminated quote accepted")
+    }
+    cmds := SplitCommands(`cd ~/docs; i;; s -f '{{.Name}};' x`)
+    if len(cmds) != 3 || cmds[2] != ` s -f '{{.Name}};' x` {
+        t.Errorf("SplitCommands = %q", cmds)
+    }
+}
+
+func TestExpand(t *testing.T) {
+    x := &Expander{Aliases: map[string]string{
+        "bez":  "r $1 offen bezahlt",
+        "paid": "bez $1; a $1 $2",
+        "vc":   "vc -l",
+        "tagall": "ax $@",
+        "loop": "loop2",
+        "loop2": "loop",
+    }}
+    cases := []struct {
+        in   []string
+        want [][]string
+    }{
+        {[]string{"bez", "3"}, [][]string{{"r", "3", "offen", "bezahlt"}}},
+        {[]string{"paid", "3", "2024"}, [][]string{{"r", "3", "offen", "bezahlt"}, {"a", "3", "2024"}}},
+        {[]string{"vc", "-new"}, [][]string{{"vc", "-l", "-new"}}},
+        {[]string{"tagall", "x", "y"}, [][]string{{"ax", "x", "y"}}},
+        {[]string{"loop"}, [][]string{{"loop"}}},
+        {[]string{"ls"}, [][]string{{"ls"}}},
+    }
+    for _, c := range cases {
+        got, err := x.Expand(c.in)
+        if err != nil || !reflect.DeepEqual(got, c.want) {
+            t.Errorf("Expand(%q) = %q, %v; want %q", c.in, got, err, c.want)
+        }
+    }
+    if _, err := x.Expand([]string{"paid", "3"}); err == nil {
+        t.Error("missing parameter accepted")
+    }
+}
+
+func TestRunnerScript(t *testing.T) {
+    var ran []string
+    var out bytes.Buffer
+    r := &Runner{Out: &out, Exec: func(args []string) error {
+        ran = append(ran, strings.Join(args, " "))
+        if args[0] == "fail" {
+            return errors.New("failed")
+        }
+        return nil
+    }}
+    script := `# comment
+a 1 x  # trailing comment
+fail; s '#not a comment'
+set -ex
+alias m = a $1 y; d $1 z
+fail
+a 2 never
+`
+    err := r.Run(strings.NewReader(script))
+    want := []string{"a 1 x", "fail", "s #not a comment", "alias m = a $1 y; d $1 z", "fail"}
+    if !reflect.DeepEqual(ran, want) {
+        t.Errorf("ran %q, want %q", ran, want)
+    }
+    if err == nil || !strings.HasPrefix(err.Error(), "line 6:") || r.Failed != 2 {
+        t.Errorf("Run = %v, %d failed", err, r.Failed)
+    }
+    if !strings.Contains(out.String(), "+ fail\n") {
+        t.Errorf("commands not echoed:\n%s", out.String())
+    }
+}
+
+func TestRegistry(t *testing.T) {
+    var ran []string
+    run := func(args []string) error {
+        ran = append(ran, strings.Join(args, " "))
+        return nil
+    }
+    r := NewRegistry()
+    r.Register(Command{Name: "a", Args: "<num> <tag>", Group: "Tags", Help: "add tag", Run: run})
+    r.Register(Command{Name: "s", Aliases: []string{"search"}, Args: "<tag...> [-f tmpl]", Group: "Search", Help: "search", Run: run})
+    r.Register(Command{Name: "trash", Args: "[ls|purge --older <age>]", Group: "Files", Help: "trash", Run: run})
+    cases := []struct {
+        args []string
+        ok   bool
+    }{
+        {[]string{"a", "1", "x"}, true},
+        {[]string{"a", "1"}, false},
+        {[]string{"a", "1", "x", "y"}, false},
+        {[]string{"search", "x", "y", "z"}, true},
+        {[]string{"s"}, false},
+        {[]string{"trash"}, true},
+        {[]string{"trash", "purge", "--older", "90d"}, true},
+        {[]string{"trash", "purge", "--older", "90d", "x"}, false},
+        {[]string{"nope"}, false},
+    }
+    for _, c := range cases {
+        if err := r.Exec(c.args); (err == nil) != c.ok {
+            t.Errorf("Exec(%q) = %v", c.args, err)
+        }
+    }
+    if err := r.Exec([]string{"a", "1"}); err == nil || err.Error() != "usage: a <num> <tag>" {
+        t.Errorf("usage error = %v", err)
+    }
+    if want := []string{"1 x", "x y z", "", "purge --older 90d"}; !reflect.DeepEqual(ran, want) {
+        t.Errorf("ran %q, want %q", ran, want)
+    }
+    var help bytes.Buffer
+    r.Help(&help, "")
+    want := `Tags:
+  a <num> <tag>                   add tag
+Search:
+  s, search <tag...> [-f tmpl]    search
+Files:
+  trash [ls|purge --older <age>]  trash
+`
+    if help.String() != want {
+        t.Errorf("help:\n%s\nwant:\n%s", help.String(), want)
+    }
+}
+
+func TestShellBuiltins(t *testing.T) {
+    t.Chdir(t.TempDir())
+    t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+    if err := os.WriteFile("scan.pdf", nil, 0644); err != nil {
+        t.Fatal(err)
+    }
+    var out bytes.Buffer
+    s := New()
+    s.Out = &out
+    for _, c := range s.Registry.Commands() {
+        if c.Help == "" || c.Group == "" {
+            t.Errorf("command %s has no help or group", c.Name)
+        }
+    }
+    if err := s.Runner.RunLine("alias tag = a 1 $1; a 1 neu"); err != nil {
+        t.Fatal(err)
+    }
+    if err := s.Runner.RunLine("init; tag steuer"); err != nil || s.Runner.Failed != 0 {
+        t.Fatalf("RunLine = %v, %d failed", err, s.Runner.Failed)
+    }
+    data, _ := os.ReadFile(".cat")
+    if got := strings.TrimSpace(string(data)); got != "scan.pdf*steuer*neu" {
+        t.Errorf(".cat = %q", got)
+    }
+    // set -e stops at commands that fail, not just at unknown ones
+    err := s.Runner.RunLine("set -e; a 9 x; a 1 never")
+    if !errors.Is(err, catalog.ErrBadIndex) || s.Runner.Failed != 1 {
+        t.Errorf("set -e: %v, %d failed", err, s.Runner.Failed)
+    }
+    s.Runner.RunLine("set +e")
+    if err := s.Execute([]string{"o"}); err == nil || err.Error() != "usage: o <num>" {
+        t.Errorf("o without number: %v", err)
+    }
+    if err := s.Execute([]string{"q"}); err != ErrQuit {
+        t.Errorf("q = %v", err)
+    }
+    out.Reset()
+    s.Execute([]string{"help"})
+    if !strings.Contains(out.String(), "a <num> <tag>") || !strings.Contains(out.String(), "Aliases:\n  tag") {
+        t.Errorf("help:\n%s", out.String())
+    }
+}
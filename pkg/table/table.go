@@ -0,0 +1,294 @@
+// Package table prints aligned text tables sized to the terminal. Widths are
+// display widths, so combining marks (NFD umlauts), wide CJK characters and
+// emoji line up.
+package table
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/mattn/go-runewidth"
+	"github.com/tenzokai/filemac/pkg/config"
+)
+
+const sep = " | "
+
+// Column describes one table column.
+type Column struct {
+	Title string
+	Min   int  // the column is never narrowed below this width
+	Max   int  // cells wider than this are shortened; 0 means no limit
+	Right bool // right-align the cells
+	// Middle shortens long cells in the middle ("2024-0...eid.pdf"), which
+	// keeps dates and extensions of file names visible.
+	Middle bool
+	// Wrap continues long cells on the next lines instead of shortening
+	// them. Wrapped columns give way first when the terminal is narrow.
+	Wrap bool
+}
+
+type row struct {
+	cells []string
+	extra []string
+}
+
+// Table collects rows and prints them.
+type Table struct {
+	Columns []Column
+	// Width is the total width to fit into; 0 means unlimited. New sets it
+	// to the terminal width.
+	Width int
+	// Color prints the header in bold.
+	Color bool
+	rows  []row
+}
+
+// New returns a table with the given columns, sized to the terminal. On a
+// terminal the header is bold unless [view] color is turned off.
+func New(cols ...Column) *Table {
+	color := false
+	if termWidth(os.Stdout.Fd()) > 0 {
+		cfg, _ := config.Load()
+		color = cfg.View.Color
+	}
+	return &Table{Columns: cols, Width: TermWidth(), Color: color}
+}
+
+// Add appends a row. Missing cells are left empty.
+func (t *Table) Add(cells ...string) {
+	t.rows = append(t.rows, row{cells: cells})
+}
+
+// Extra adds a free-form line below the last row, indented to the second
+// column.
+func (t *Table) Extra(line string) {
+	if len(t.rows) > 0 {
+		r := &t.rows[len(t.rows)-1]
+		r.extra = append(r.extra, line)
+	}
+}
+
+// Len returns the number of rows.
+func (t *Table) Len() int {
+	return len(t.rows)
+}
+
+// Print writes the table to stdout.
+func (t *Table) Print() {
+	t.Render(os.Stdout)
+}
+
+// Render writes the header and all rows to w.
+func (t *Table) Render(w io.Writer) {
+	widths := t.widths()
+	var b strings.Builder
+	for i, c := range t.Columns {
+		if i > 0 {
+			b.WriteString(sep)
+		}
+		b.WriteString(pad(Fit(c.Title, widths[i], false), widths[i], false, i == len(t.Columns)-1))
+	}
+	header := strings.TrimRight(b.String(), " ")
+	if t.Color {
+		header = "\x1b[1m" + header + "\x1b[0m"
+	}
+	fmt.Fprintln(w, header)
+
+	indent := 0
+	if len(widths) > 1 {
+		indent = widths[0] + len(sep)
+	}
+	for _, r := range t.rows {
+		lines := [][]string{}
+		height := 1
+		for i, c := range t.Columns {
+			cell := ""
+			if i < len(r.cells) {
+				cell = r.cells[i]
+			}
+			var parts []string
+			if c.Wrap {
+				parts = wrap(cell, widths[i])
+			} else {
+				parts = []string{Fit(cell, widths[i], c.Middle)}
+			}
+			if len(parts) > height {
+				height = len(parts)
+			}
+			lines = append(lines, parts)
+		}
+		for l := 0; l < height; l++ {
+			b.Reset()
+			for i, c := range t.Columns {
+				if i > 0 {
+					b.WriteString(sep)
+				}
+				cell := ""
+				if l < len(lines[i]) {
+					cell = lines[i][l]
+				}
+				b.WriteString(pad(cell, widths[i], c.Right, i == len(t.Columns)-1))
+			}
+			fmt.Fprintln(w, strings.TrimRight(b.String(), " "))
+		}
+		for _, x := range r.extra {
+			line := strings.Repeat(" ", indent) + x
+			if t.Width > 0 {
+				line = Fit(line, t.Width, false)
+			}
+			fmt.Fprintln(w, line)
+		}
+	}
+}
+
+// widths returns the width of each column: the widest cell, capped at Max,
+// and narrowed to fit Width. Wrapped columns are narrowed first, then
+// columns that are shortened, right to left, but never below Min.
+func (t *Table) widths() []int {
+	widths := make([]int, len(t.Columns))
+	for i, c := range t.Columns {
+		widths[i] = Width(c.Title)
+		for _, r := range t.rows {
+			if i < len(r.cells) && Width(r.cells[i]) > widths[i] {
+				widths[i] = Width(r.cells[i])
+			}
+		}
+		if c.Max > 0 && widths[i] > c.Max {
+			widths[i] = c.Max
+		}
+		if widths[i] < c.Min {
+			widths[i] = c.Min
+		}
+	}
+	if t.Width <= 0 {
+		return widths
+	}
+	total := len(sep) * (len(widths) - 1)
+	for _, w := range widths {
+		total += w
+	}
+	for _, wrapped := range []bool{true, false} {
+		for i := len(t.Columns) - 1; i >= 0 && total > t.Width; i-- {
+			c := t.Columns[i]
+			if c.Wrap != wrapped {
+				continue
+			}
+			min := c.Min
+			if min < 1 {
+				min = 1
+			}
+			cut := total - t.Width
+			if widths[i]-cut < min {
+				cut = widths[i] - min
+			}
+			if cut > 0 {
+				widths[i] -= cut
+				total -= cut
+			}
+		}
+	}
+	return widths
+}
+
+// Width returns the display width of s in terminal cells, counting each
+// grapheme cluster (e.g. a flag or an emoji with skin tone) at most two.
+func Width(s string) int {
+	return runewidth.StringWidth(s)
+}
+
+// Fit shortens s to at most w cells, replacing the cut part with "...", at
+// the end or, with middle set, in the middle.
+func Fit(s string, w int, middle bool) string {
+	if Width(s) <= w {
+		return s
+	}
+	if w <= 3 {
+		return takeLeft(s, w)
+	}
+	if !middle {
+		return takeLeft(s, w-3) + "..."
+	}
+	tail := (w - 2) / 2
+	return takeLeft(s, w-3-tail) + "..." + takeRight(s, tail)
+}
+
+// takeLeft returns the longest prefix of s at most w cells wide. Like
+// takeRight it cuts between grapheme clusters, so flags, emoji with skin
+// tones and combining marks stay whole.
+func takeLeft(s string, w int) string {
+	return runewidth.Truncate(s, w, "")
+}
+
+// takeRight returns the longest suffix of s at most w cells wide.
+func takeRight(s string, w int) string {
+	return runewidth.TruncatePrefix(s, w, "")
+}
+
+// wrap breaks s into lines of at most w cells, at spaces where possible.
+func wrap(s string, w int) []string {
+	if Width(s) <= w || w < 1 {
+		return []string{s}
+	}
+	var lines []string
+	line := ""
+	for _, word := range strings.Fields(s) {
+		for Width(word) > w {
+			if line != "" {
+				lines = append(lines, line)
+				line = ""
+			}
+			head := takeLeft(word, w)
+			if head == "" {
+				_, size := utf8.DecodeRuneInString(word)
+				head = word[:size]
+			}
+			lines = append(lines, head)
+			word = word[len(head):]
+		}
+		switch {
+		case line == "":
+			line = word
+		case Width(line)+1+Width(word) <= w:
+			line += " " + word
+		default:
+			lines = append(lines, line)
+			line = word
+		}
+	}
+	if line != "" {
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// pad fills s with spaces to w cells. Left-aligned cells of the last column
+// are left alone, so lines carry no trailing spaces.
+func pad(s string, w int, right, last bool) string {
+	gap := w - Width(s)
+	if gap <= 0 {
+		return s
+	}
+	if right {
+		return strings.Repeat(" ", gap) + s
+	}
+	if last {
+		return s
+	}
+	return s + strings.Repeat(" ", gap)
+}
+
+// TermWidth returns the width of the terminal on stdout, or $COLUMNS, or 0
+// when output does not go to a terminal.
+func TermWidth() int {
+	if w := termWidth(os.Stdout.Fd()); w > 0 {
+		return w
+	}
+	if n, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && n > 0 {
+		return n
+	}
+	return 0
+}
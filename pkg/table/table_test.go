@@ -0,0 +1,84 @@
+package table
+
+import (
+    "bytes"
+    "strings"
+    "testing"
+)
+
+func TestFit(t *testing.T) {
+    cases := []struct {
+        in     string
+        w      int
+        middle bool
+        want   string
+    }{
+        {"kurz.pdf", 10, true, "kurz.pdf"},
+        {"2024-05-02_Steuerbescheid.pdf", 16, true, "2024-0...eid.pdf"},
+        {"2024-05-02_Steuerbescheid.pdf", 16, false, "2024-05-02_St..."},
+        {"日本語のファイル.txt", 10, false, "日本語..."},
+        {"Gebu\u0308hren.pdf", 12, false, "Gebu\u0308hren.pdf"},
+        {"Gebu\u0308hren_2024.pdf", 12, true, "Gebu\u0308...4.pdf"},
+    }
+    for _, c := range cases {
+        got := Fit(c.in, c.w, c.middle)
+        if got != c.want {
+            t.Errorf("Fit(%q, %d) = %q, want %q", c.in, c.w, got, c.want)
+        }
+        if Width(got) > c.w {
+            t.Errorf("Fit(%q, %d) is %d wide", c.in, c.w, Width(got))
+        }
+    }
+}
+
+func TestWidth(t *testing.T) {
+    for s, want := range map[string]int{
+        "abc":          3,
+        "Gebu\u0308hr": 6,
+        "日本":           4,
+        "\U0001F1E9\U0001F1EA":             2, // flag DE
+        "\U0001F44D\U0001F3FD":             2, // thumbs up, medium skin tone
+        "Urlaub \U0001F1EE\U0001F1F9.jpg": 13,
+    } {
+        if got := Width(s); got != want {
+            t.Errorf("Width(%q) = %d, want %d", s, got, want)
+        }
+    }
+    flags := strings.Repeat("\U0001F1E9\U0001F1EA", 4)
+    if got := Fit(flags, 5, false); got != "\U0001F1E9\U0001F1EA..." {
+        t.Errorf("Fit cut a flag: %q", got)
+    }
+    if got := Fit(flags, 7, true); got != "\U0001F1E9\U0001F1EA...\U0001F1E9\U0001F1EA" {
+        t.Errorf("Fit(middle) cut a flag: %q", got)
+    }
+}
+
+func TestRenderAlignsAndWraps(t *testing.T) {
+    tbl := &Table{Width: 40, Columns: []Column{
+        {Title: "num", Min: 3},
+        {Title: "name", Min: 10, Middle: true},
+        {Title: "tags", Min: 10, Wrap: true},
+    }}
+    tbl.Add("1", "Gebu\u0308hren.pdf", "steuer")
+    tbl.Add("2", "日本.txt", "a, b")
+    tbl.Add("3", "2024-05-02_Steuerbescheid_Finanzamt.pdf", "steuer, 2024, bescheid, finanzamt")
+    var buf bytes.Buffer
+    tbl.Render(&buf)
+    lines := strings.Split(strings.TrimRight(buf.String(), "\n"), "\n")
+    col := -1
+    for _, l := range lines {
+        if Width(l) > 40 {
+            t.Errorf("line wider than 40: %q", l)
+        }
+        // the second separator must sit in the same cell on every line
+        i := strings.LastIndex(l, " | ")
+        if w := Width(l[:i]); col == -1 {
+            col = w
+        } else if w != col {
+            t.Errorf("misaligned line %q", l)
+        }
+    }
+    if len(lines) < 5 {
+        t.Errorf("long tags not wrapped:\n%s", buf.String())
+    }
+}
@@ -0,0 +1,7 @@
+//go:build !linux && !darwin && !freebsd && !netbsd && !openbsd
+
+package table
+
+func termWidth(fd uintptr) int {
+	return 0
+}
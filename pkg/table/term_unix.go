@@ -0,0 +1,13 @@
+//go:build linux || darwin || freebsd || netbsd || openbsd
+
+package table
+
+import "golang.org/x/sys/unix"
+
+func termWidth(fd uintptr) int {
+	ws, err := unix.IoctlGetWinsize(int(fd), unix.TIOCGWINSZ)
+	if err != nil {
+		return 0
+	}
+	return int(ws.Col)
+}
@@ -0,0 +1,13 @@
+package tags
+
+import "errors"
+
+// Errors returned by the tag commands, wrapped with details; check for them
+// with errors.Is. Catalog problems are reported with the catalog errors,
+// e.g. catalog.ErrBadIndex.
+var (
+	ErrEmptyTag    = errors.New("empty tag not allowed")
+	ErrTagExists   = errors.New("tag already present")
+	ErrTagNotFound = errors.New("tag not found")
+	ErrUnknownTag  = errors.New("tag not in the vocabulary")
+)
@@ -0,0 +1,83 @@
+package tags
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/tenzokai/filemac/pkg/catalog"
+	"github.com/tenzokai/filemac/pkg/config"
+)
+
+// SearchResult is the data passed to search output templates (-f).
+type SearchResult struct {
+	Path  string // absolute file path, or the URL itself
+	Name  string // name as stored in .cat
+	Dir   string // folder holding the .cat
+	Type  string // "file" or "url"
+	Tags  []string
+	Title string // optional title of URL entries
+	Note  string // free-text note
+}
+
+// newResult builds the search result for e, found in the catalog of dir.
+// File paths are made absolute; URL entries keep their URL as path.
+func newResult(e catalog.CatEntry, dir string) SearchResult {
+	path := e.Name
+	if e.Type == "file" && dir != "" && !filepath.IsAbs(path) {
+		path = filepath.Join(dir, path)
+		path, _ = filepath.Abs(path)
+	}
+	return SearchResult{Path: path, Name: e.Name, Dir: dir, Type: e.Type, Tags: e.Tags, Title: e.Title, Note: e.Note}
+}
+
+var templateFuncs = template.FuncMap{
+	"join":  func(list []string, sep string) string { return strings.Join(list, sep) },
+	"upper": strings.ToUpper,
+	"lower": strings.ToLower,
+}
+
+// escapes lets templates typed on the command line use \t and \n.
+var escapes = strings.NewReplacer(`\t`, "\t", `\n`, "\n")
+
+// splitFormatArg removes "-f <template>" from the search terms and returns
+// the remaining terms and the template text (empty if none was given).
+func splitFormatArg(args []string) ([]string, string, error) {
+	var terms []string
+	format := ""
+	for i := 0; i < len(args); i++ {
+		if args[i] == "-f" {
+			if i+1 >= len(args) {
+				return nil, "", fmt.Errorf("-f needs a template or preset name")
+			}
+			format = args[i+1]
+			i++
+			continue
+		}
+		terms = append(terms, args[i])
+	}
+	return terms, format, nil
+}
+
+// parseResultTemplate returns the template for format. If format names a
+// preset from the [templates] section of the config, the preset is used.
+func parseResultTemplate(format string) (*template.Template, error) {
+	cfg, err := config.Load()
+	if err != nil {
+		return nil, err
+	}
+	text := format
+	if preset, ok := cfg.Templates[format]; ok {
+		text = preset
+	}
+	text = escapes.Replace(text)
+	if !strings.HasSuffix(text, "\n") {
+		text += "\n"
+	}
+	tmpl, err := template.New("result").Funcs(templateFuncs).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("template error: %w", err)
+	}
+	return tmpl, nil
+}
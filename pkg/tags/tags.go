@@ -3,23 +3,34 @@ package tags
 import (
 	"bufio"
 	"fmt"
+	"io"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"sort"
 	"strconv"
 	"strings"
+	"text/template"
 
 	"github.com/peterh/liner"
 	"github.com/tenzokai/filemac/pkg/catalog"
+	"github.com/tenzokai/filemac/pkg/opener"
+	"github.com/tenzokai/filemac/pkg/table"
+	"github.com/tenzokai/filemac/pkg/utils"
 )
 
-// List unique tags
-func CmdListTags() {
+// List unique tags; lt -c adds how many entries carry each tag.
+func CmdListTags(w io.Writer, args ...string) error {
+	counts := false
+	for _, a := range args {
+		if a != "-c" {
+			return fmt.Errorf("usage: lt [-c]")
+		}
+		counts = true
+	}
 	cwd, _ := os.Getwd()
 	catPath := filepath.Join(cwd, ".cat")
 	linkPath := filepath.Join(cwd, ".catlink")
-	tagSet := make(map[string]struct{})
+	tagSet := make(map[string]int)
 	catExists := func(file string) bool {
 		s, err := os.Stat(file)
 		return err == nil && !s.IsDir()
@@ -28,20 +39,18 @@ func CmdListTags() {
 	if catExists(catPath) {
 		entries, err := catalog.LoadCatalog()
 		if err != nil {
-			fmt.Println("catalog error:", err)
-			return
+			return err
 		}
 		for _, ent := range entries {
 			for _, tag := range ent.Tags {
-				tagSet[tag] = struct{}{}
+				tagSet[tag]++
 			}
 		}
 	} else if catExists(linkPath) {
 		// Aggregate tags from all linked cats
 		f, err := os.Open(linkPath)
 		if err != nil {
-			fmt.Println("could not open .catlink:", err)
-			return
+			return fmt.Errorf("could not open .catlink: %w", err)
 		}
 		defer f.Close()
 		scanner := bufio.NewScanner(f)
@@ -61,13 +70,12 @@ func CmdListTags() {
 			}
 			for _, ent := range entries {
 				for _, tag := range ent.Tags {
-					tagSet[tag] = struct{}{}
+					tagSet[tag]++
 				}
 			}
 		}
 	} else {
-		fmt.Println("(no .cat or .catlink found, no tags)")
-		return
+		return fmt.Errorf("%w (.cat or .catlink) in %s", catalog.ErrNoCatalog, cwd)
 	}
 	var tags []string
 	for tag := range tagSet {
@@ -75,56 +83,80 @@ func CmdListTags() {
 	}
 	sort.Strings(tags)
 	if len(tags) == 0 {
-		fmt.Println("(no tags found)")
-		return
+		fmt.Fprintln(w, "(no tags found)")
+		return nil
 	}
+	if !counts {
+		for _, tag := range tags {
+			fmt.Fprintln(w, tag)
+		}
+		return nil
+	}
+	t := table.New(
+		table.Column{Title: "tag", Min: 10, Middle: true},
+		table.Column{Title: "entries", Right: true},
+	)
 	for _, tag := range tags {
-		fmt.Println(tag)
+		t.Add(tag, strconv.Itoa(tagSet[tag]))
 	}
+	t.Render(w)
+	return nil
 }
 
-func CmdAddTag(numStr string, tag string) {
+// CmdAddTag adds tag to entry num. It returns ErrTagExists if the entry
+// already has it.
+func CmdAddTag(w io.Writer, numStr string, tag string) error {
+	catalog.Lock()
+	defer catalog.Unlock()
 	entries, err := catalog.LoadCatalog()
 	if err != nil {
-		fmt.Println("catalog error:", err)
-		return
+		return err
 	}
-	n, err := strconv.Atoi(numStr)
-	if err != nil || n < 1 || n > len(entries) {
-		fmt.Printf("invalid entry number: %v\n", numStr)
-		return
+	i, err := catalog.EntryIndex(numStr, len(entries))
+	if err != nil {
+		return err
 	}
-	entry := &entries[n-1]
+	entry := &entries[i]
 	tag = strings.TrimSpace(tag)
 	if tag == "" {
-		fmt.Println("Empty tag not allowed")
-		return
+		return ErrEmptyTag
+	}
+	if err := catalog.CheckTag(tag); err != nil {
+		return err
+	}
+	if err := allowTag(w, tag); err != nil {
+		return err
 	}
 	for _, t := range entry.Tags {
 		if t == tag {
-			fmt.Println("tag already present")
-			return
+			return fmt.Errorf("%w: '%s' on entry %d", ErrTagExists, tag, i+1)
 		}
 	}
 	entry.Tags = append(entry.Tags, tag)
 	if err := catalog.SaveCatalog(entries); err != nil {
-		fmt.Println("error saving catalog:", err)
-		return
+		return fmt.Errorf("saving catalog: %w", err)
 	}
-	fmt.Printf("tag '%s' added to entry %d\n", tag, n)
+	fmt.Fprintf(w, "tag '%s' added to entry %d\n", tag, i+1)
+	return nil
 }
 
-// saveCatalog writes all entries back to .cat
-func CmdAddTagAll(tag string) {
+// CmdAddTagAll adds tag to every entry that lacks it.
+func CmdAddTagAll(w io.Writer, tag string) error {
+	catalog.Lock()
+	defer catalog.Unlock()
 	entries, err := catalog.LoadCatalog()
 	if err != nil {
-		fmt.Println("catalog error:", err)
-		return
+		return err
 	}
 	tag = strings.TrimSpace(tag)
 	if tag == "" {
-		fmt.Println("Empty tag not allowed")
-		return
+		return ErrEmptyTag
+	}
+	if err := catalog.CheckTag(tag); err != nil {
+		return err
+	}
+	if err := allowTag(w, tag); err != nil {
+		return err
 	}
 	count := 0
 	for i := range entries {
@@ -141,31 +173,33 @@ func CmdAddTagAll(tag string) {
 		}
 	}
 	if count == 0 {
-		fmt.Println("tag already present on all entries, nothing to add")
-		return
+		fmt.Fprintln(w, "tag already present on all entries, nothing to add")
+		return nil
 	}
 	if err := catalog.SaveCatalog(entries); err != nil {
-		fmt.Println("error saving catalog:", err)
-		return
+		return fmt.Errorf("saving catalog: %w", err)
 	}
-	fmt.Printf("tag '%s' added to %d entries\n", tag, count)
+	fmt.Fprintf(w, "tag '%s' added to %d entries\n", tag, count)
+	return nil
 }
-func CmdRemoveTag(numStr string, tag string) {
+
+// CmdRemoveTag removes tag from entry num. It returns ErrTagNotFound if the
+// entry does not have it.
+func CmdRemoveTag(w io.Writer, numStr string, tag string) error {
+	catalog.Lock()
+	defer catalog.Unlock()
 	entries, err := catalog.LoadCatalog()
 	if err != nil {
-		fmt.Println("catalog error:", err)
-		return
+		return err
 	}
-	n, err := strconv.Atoi(numStr)
-	if err != nil || n < 1 || n > len(entries) {
-		fmt.Printf("invalid entry number: %v\n", numStr)
-		return
+	i, err := catalog.EntryIndex(numStr, len(entries))
+	if err != nil {
+		return err
 	}
-	entry := &entries[n-1]
+	entry := &entries[i]
 	tag = strings.TrimSpace(tag)
 	if tag == "" {
-		fmt.Println("Empty tag not allowed")
-		return
+		return ErrEmptyTag
 	}
 	found := false
 	var newTags []string
@@ -177,26 +211,27 @@ func CmdRemoveTag(numStr string, tag string) {
 		newTags = append(newTags, t)
 	}
 	if !found {
-		fmt.Println("tag not found on entry")
-		return
+		return fmt.Errorf("%w: '%s' on entry %d", ErrTagNotFound, tag, i+1)
 	}
 	entry.Tags = newTags
 	if err := catalog.SaveCatalog(entries); err != nil {
-		fmt.Println("error saving catalog:", err)
-		return
+		return fmt.Errorf("saving catalog: %w", err)
 	}
-	fmt.Printf("tag '%s' removed from entry %d\n", tag, n)
+	fmt.Fprintf(w, "tag '%s' removed from entry %d\n", tag, i+1)
+	return nil
 }
-func CmdRemoveTagAll(tag string) {
+
+// CmdRemoveTagAll removes tag from every entry.
+func CmdRemoveTagAll(w io.Writer, tag string) error {
+	catalog.Lock()
+	defer catalog.Unlock()
 	entries, err := catalog.LoadCatalog()
 	if err != nil {
-		fmt.Println("catalog error:", err)
-		return
+		return err
 	}
 	tag = strings.TrimSpace(tag)
 	if tag == "" {
-		fmt.Println("Empty tag not allowed")
-		return
+		return ErrEmptyTag
 	}
 	count := 0
 	for i := range entries {
@@ -215,37 +250,44 @@ func CmdRemoveTagAll(tag string) {
 		}
 	}
 	if count == 0 {
-		fmt.Println("tag not found on any entry, nothing to remove")
-		return
+		fmt.Fprintln(w, "tag not found on any entry, nothing to remove")
+		return nil
 	}
 	if err := catalog.SaveCatalog(entries); err != nil {
-		fmt.Println("error saving catalog:", err)
-		return
+		return fmt.Errorf("saving catalog: %w", err)
 	}
-	fmt.Printf("tag '%s' removed from %d entries\n", tag, count)
+	fmt.Fprintf(w, "tag '%s' removed from %d entries\n", tag, count)
+	return nil
 }
-func CmdReplaceTag(numStr, t1, t2 string) {
+
+// CmdReplaceTag replaces t1 with t2 in entry num. It returns ErrTagNotFound
+// if the entry does not have t1.
+func CmdReplaceTag(w io.Writer, numStr, t1, t2 string) error {
+	catalog.Lock()
+	defer catalog.Unlock()
 	entries, err := catalog.LoadCatalog()
 	if err != nil {
-		fmt.Println("catalog error:", err)
-		return
+		return err
 	}
-	n, err := strconv.Atoi(numStr)
-	if err != nil || n < 1 || n > len(entries) {
-		fmt.Printf("invalid entry number: %v\n", numStr)
-		return
+	i, err := catalog.EntryIndex(numStr, len(entries))
+	if err != nil {
+		return err
 	}
 	t1 = strings.TrimSpace(t1)
 	t2 = strings.TrimSpace(t2)
 	if t1 == "" || t2 == "" {
-		fmt.Println("tags must be non-empty")
-		return
+		return ErrEmptyTag
+	}
+	if err := catalog.CheckTag(t2); err != nil {
+		return err
+	}
+	if err := allowTag(w, t2); err != nil {
+		return err
 	}
 	if t1 == t2 {
-		fmt.Println("tags must be different")
-		return
+		return fmt.Errorf("tags must be different")
 	}
-	entry := &entries[n-1]
+	entry := &entries[i]
 	found := false
 	already := false
 	for _, t := range entry.Tags {
@@ -257,8 +299,7 @@ func CmdReplaceTag(numStr, t1, t2 string) {
 		}
 	}
 	if !found {
-		fmt.Println("tag not found on entry")
-		return
+		return fmt.Errorf("%w: '%s' on entry %d", ErrTagNotFound, t1, i+1)
 	}
 	var newTags []string
 	for _, t := range entry.Tags {
@@ -272,26 +313,33 @@ func CmdReplaceTag(numStr, t1, t2 string) {
 	}
 	entry.Tags = newTags
 	if err := catalog.SaveCatalog(entries); err != nil {
-		fmt.Println("error saving catalog:", err)
-		return
+		return fmt.Errorf("saving catalog: %w", err)
 	}
-	fmt.Printf("tag '%s' replaced with '%s' in entry %d\n", t1, t2, n)
+	fmt.Fprintf(w, "tag '%s' replaced with '%s' in entry %d\n", t1, t2, i+1)
+	return nil
 }
-func CmdReplaceTagAll(t1, t2 string) {
+
+// CmdReplaceTagAll replaces t1 with t2 in every entry.
+func CmdReplaceTagAll(w io.Writer, t1, t2 string) error {
+	catalog.Lock()
+	defer catalog.Unlock()
 	entries, err := catalog.LoadCatalog()
 	if err != nil {
-		fmt.Println("catalog error:", err)
-		return
+		return err
 	}
 	t1 = strings.TrimSpace(t1)
 	t2 = strings.TrimSpace(t2)
 	if t1 == "" || t2 == "" {
-		fmt.Println("tags must be non-empty")
-		return
+		return ErrEmptyTag
+	}
+	if err := catalog.CheckTag(t2); err != nil {
+		return err
+	}
+	if err := allowTag(w, t2); err != nil {
+		return err
 	}
 	if t1 == t2 {
-		fmt.Println("tags must be different")
-		return
+		return fmt.Errorf("tags must be different")
 	}
 	count := 0
 	for i := range entries {
@@ -322,63 +370,51 @@ func CmdReplaceTagAll(t1, t2 string) {
 		count++
 	}
 	if count == 0 {
-		fmt.Println("tag not found on any entry")
-		return
+		fmt.Fprintln(w, "tag not found on any entry")
+		return nil
 	}
 	if err := catalog.SaveCatalog(entries); err != nil {
-		fmt.Println("error saving catalog:", err)
-		return
+		return fmt.Errorf("saving catalog: %w", err)
 	}
-	fmt.Printf("tag '%s' replaced with '%s' in %d entries\n", t1, t2, count)
+	fmt.Fprintf(w, "tag '%s' replaced with '%s' in %d entries\n", t1, t2, count)
+	return nil
 }
 
-func CmdSearch(tags []string) {
-	// Split search terms into includes and excludes
-	var includes, excludes []string
-	for _, t := range tags {
-		t = strings.TrimSpace(t)
-		if strings.HasPrefix(t, "!") && len(t) > 1 {
-			excludes = append(excludes, t[1:])
-		} else if t != "" {
-			includes = append(includes, t)
-		}
+// CmdSearch prints all entries carrying every given tag. "-f <template>"
+// renders each result with a text/template (or a preset from config).
+func CmdSearch(w io.Writer, tags []string) error {
+	terms, format, err := splitFormatArg(tags)
+	if err != nil {
+		return err
 	}
-
-	hasTags := func(entryTags []string) bool {
-		// Must have all includes
-		for _, want := range includes {
-			found := false
-			for _, have := range entryTags {
-				if want == have {
-					found = true
-					break
-				}
-			}
-			if !found {
-				return false
-			}
-		}
-		// Must have none of the excludes
-		for _, notag := range excludes {
-			for _, have := range entryTags {
-				if notag == have {
-					return false
-				}
-			}
+	var tmpl *template.Template
+	if format != "" {
+		tmpl, err = parseResultTemplate(format)
+		if err != nil {
+			return err
 		}
-		return true
 	}
 
-	// Helper for printing result lines
+	// Results are collected into a table unless a template is given
+	results := table.New(
+		table.Column{Title: "path", Min: 16, Middle: true},
+		table.Column{Title: "tags", Min: 10, Wrap: true},
+	)
+	defer func() {
+		if tmpl == nil && results.Len() > 0 {
+			results.Render(w)
+		}
+	}()
+	var tmplErr error
 	printEntry := func(e catalog.CatEntry, dir string) {
-		path := e.Name
-		if e.Type == "file" && dir != "" && !filepath.IsAbs(path) {
-			path = filepath.Join(dir, path)
-			path, _ = filepath.Abs(path)
+		r := newResult(e, dir)
+		if tmpl != nil {
+			if err := tmpl.Execute(w, r); err != nil && tmplErr == nil {
+				tmplErr = fmt.Errorf("template error: %w", err)
+			}
+			return
 		}
-		fmt.Println(path)
-		fmt.Println("   " + strings.Join(e.Tags, ", "))
-		fmt.Println("")
+		results.Add(r.Path, strings.Join(r.Tags, ", "))
 	}
 
 	catExists := func(file string) bool {
@@ -393,21 +429,19 @@ func CmdSearch(tags []string) {
 	if catExists(catPath) {
 		entries, err := catalog.LoadCatalog()
 		if err != nil {
-			fmt.Println("catalog error:", err)
-			return
+			return err
 		}
 		for _, e := range entries {
-			if hasTags(e.Tags) {
+			if MatchEntry(terms, e) {
 				printEntry(e, cwd)
 			}
 		}
-		return
+		return tmplErr
 	} else if catExists(linkPath) {
 		// Search all referenced .cat files
 		f, err := os.Open(linkPath)
 		if err != nil {
-			fmt.Println("could not open .catlink:", err)
-			return
+			return fmt.Errorf("could not open .catlink: %w", err)
 		}
 		defer f.Close()
 		scanner := bufio.NewScanner(f)
@@ -422,19 +456,78 @@ func CmdSearch(tags []string) {
 			}
 			entries, err := readCatalogAt(catfile)
 			if err != nil {
-				fmt.Printf("error reading %s: %v\n", catfile, err)
+				fmt.Fprintf(w, "error reading %s: %v\n", catfile, err)
 				continue
 			}
 			for _, e := range entries {
-				if hasTags(e.Tags) {
+				if MatchEntry(terms, e) {
 					printEntry(e, dir)
 				}
 			}
 		}
-		return
+		return tmplErr
 	}
 	// Neither .cat nor .catlink
-	fmt.Printf("(No .cat or .catlink found in %s)\n", cwd)
+	return fmt.Errorf("%w (.cat or .catlink) in %s", catalog.ErrNoCatalog, cwd)
+}
+
+// MatchEntry reports whether e satisfies all search terms. Terms are tags,
+// "note:text" (note equals text) or "note:~text" (note contains text), both
+// case-insensitive; a leading '!' negates a term.
+func MatchEntry(terms []string, e catalog.CatEntry) bool {
+	for _, t := range terms {
+		t = strings.TrimSpace(t)
+		negate := false
+		if strings.HasPrefix(t, "!") && len(t) > 1 {
+			negate = true
+			t = t[1:]
+		}
+		if t == "" {
+			continue
+		}
+		var ok bool
+		if strings.HasPrefix(t, "note:") {
+			ok = matchNote(strings.TrimPrefix(t, "note:"), e.Note)
+		} else {
+			ok = MatchTags([]string{t}, e.Tags)
+		}
+		if ok == negate {
+			return false
+		}
+	}
+	return true
+}
+
+func matchNote(pattern, note string) bool {
+	note = strings.ToLower(note)
+	if strings.HasPrefix(pattern, "~") {
+		return strings.Contains(note, strings.ToLower(pattern[1:]))
+	}
+	return note == strings.ToLower(pattern)
+}
+
+// MatchTags reports whether entryTags satisfy the search terms: every plain
+// term must be present and no "!term" may be.
+func MatchTags(terms []string, entryTags []string) bool {
+	has := func(tag string) bool {
+		for _, t := range entryTags {
+			if t == tag {
+				return true
+			}
+		}
+		return false
+	}
+	for _, t := range terms {
+		t = strings.TrimSpace(t)
+		if strings.HasPrefix(t, "!") && len(t) > 1 {
+			if has(t[1:]) {
+				return false
+			}
+		} else if t != "" && !has(t) {
+			return false
+		}
+	}
+	return true
 }
 
 // Like LoadCatalog but at arbitrary filename
@@ -461,43 +554,42 @@ func readCatalogAt(filename string) ([]catalog.CatEntry, error) {
 }
 
 // CmdSearchLoop: interactive search and open
-func CmdSearchLoop() {
+func CmdSearchLoop(w io.Writer) error {
 	line := liner.NewLiner()
 	defer line.Close()
 	line.SetCtrlCAborts(true)
 	var history []string
 	//reader := bufio.NewReader(os.Stdin)
 
-	type Match struct {
-		Tags []string
-		Path string
-		Type string // file/url
-	}
-
-	var matches []Match
+	var matches []SearchResult
+	var tmpl *template.Template
 	var printResults = func() {
 		if len(matches) == 0 {
-			fmt.Println("No matches.")
+			fmt.Fprintln(w, "No matches.")
 			return
 		}
+		t := table.New(
+			table.Column{Title: "num", Min: 4},
+			table.Column{Title: "path", Min: 16, Middle: true},
+			table.Column{Title: "tags", Min: 10, Wrap: true},
+		)
 		for idx, m := range matches {
-			fmt.Printf("%d ", idx+1)
-			fmt.Println(strings.Join(m.Tags, " "))
-			fmt.Println(m.Path)
+			if tmpl != nil {
+				fmt.Fprintf(w, "%d ", idx+1)
+				if err := tmpl.Execute(w, m); err != nil {
+					fmt.Fprintln(w, "template error:", err)
+				}
+				continue
+			}
+			t.Add(strconv.Itoa(idx+1), m.Path, strings.Join(m.Tags, ", "))
+		}
+		if tmpl == nil {
+			t.Render(w)
 		}
 	}
 
 	var performSearch = func(q []string) {
 		// search logic from CmdSearch, but stores matches as []Match
-		var includes, excludes []string
-		for _, t := range q {
-			t = strings.TrimSpace(t)
-			if strings.HasPrefix(t, "!") && len(t) > 1 {
-				excludes = append(excludes, t[1:])
-			} else if t != "" {
-				includes = append(includes, t)
-			}
-		}
 		matches = matches[:0]
 		cwd, _ := os.Getwd()
 		catfile := filepath.Join(cwd, ".cat")
@@ -506,50 +598,23 @@ func CmdSearchLoop() {
 			s, err := os.Stat(file)
 			return err == nil && !s.IsDir()
 		}
-		hasTags := func(entryTags []string) bool {
-			for _, want := range includes {
-				found := false
-				for _, have := range entryTags {
-					if want == have {
-						found = true
-						break
-					}
-				}
-				if !found {
-					return false
-				}
-			}
-			for _, notag := range excludes {
-				for _, have := range entryTags {
-					if notag == have {
-						return false
-					}
-				}
-			}
-			return true
-		}
 		// catalog check
 		if catExists(catfile) {
 			entries, err := catalog.LoadCatalog()
 			if err != nil {
-				fmt.Println("catalog error:", err)
+				fmt.Fprintln(w, "catalog error:", err)
 				return
 			}
 			for _, e := range entries {
-				if hasTags(e.Tags) {
-					abspath := e.Name
-					if e.Type == "file" && !filepath.IsAbs(e.Name) {
-						abspath = filepath.Join(cwd, e.Name)
-						abspath, _ = filepath.Abs(abspath)
-					}
-					matches = append(matches, Match{Tags: e.Tags, Path: abspath, Type: e.Type})
+				if MatchEntry(q, e) {
+					matches = append(matches, newResult(e, cwd))
 				}
 			}
 			return
 		} else if catExists(linkfile) {
 			f, err := os.Open(linkfile)
 			if err != nil {
-				fmt.Println("could not open .catlink:", err)
+				fmt.Fprintln(w, "could not open .catlink:", err)
 				return
 			}
 			defer f.Close()
@@ -568,30 +633,25 @@ func CmdSearchLoop() {
 					continue
 				}
 				for _, e := range entries {
-					if hasTags(e.Tags) {
-						abspath := e.Name
-						if e.Type == "file" && !filepath.IsAbs(e.Name) {
-							abspath = filepath.Join(dir, e.Name)
-							abspath, _ = filepath.Abs(abspath)
-						}
-						matches = append(matches, Match{Tags: e.Tags, Path: abspath, Type: e.Type})
+					if MatchEntry(q, e) {
+						matches = append(matches, newResult(e, dir))
 					}
 				}
 			}
 			return
 		}
-		fmt.Println("(No .cat or .catlink found in current dir)")
+		fmt.Fprintln(w, "(No .cat or .catlink found in current dir)")
 	}
 
-	fmt.Println("Interactive search loop. Enter:")
-	fmt.Println("    s <tag> [!notag] ...   to (re)search")
-	fmt.Println("    o <n> | o <path>       to open match")
-	fmt.Println("    q                      to quit")
+	fmt.Fprintln(w, "Interactive search loop. Enter:")
+	fmt.Fprintln(w, "    s <tag> [!notag] ...   to (re)search (-f <template> for custom output)")
+	fmt.Fprintln(w, "    o <n> | o <path>       to open match")
+	fmt.Fprintln(w, "    q                      to quit")
 	for {
 		input, err := line.Prompt("SearchLoop> ")
 		if err != nil {
-			fmt.Println()
-			return
+			fmt.Fprintln(w)
+			return nil
 		}
 		input = strings.TrimSpace(input)
 		if input == "" {
@@ -599,7 +659,11 @@ func CmdSearchLoop() {
 		}
 		line.AppendHistory(input)
 		history = append(history, input)
-		parts := strings.Fields(input)
+		parts, err := utils.SplitWords(input)
+		if err != nil {
+			fmt.Fprintln(w, err)
+			continue
+		}
 
 		if len(parts) == 0 {
 			continue
@@ -607,20 +671,32 @@ func CmdSearchLoop() {
 		cmd := parts[0]
 		switch cmd {
 		case "q":
-			fmt.Println("Quitting.")
-			return
+			fmt.Fprintln(w, "Quitting.")
+			return nil
 		case "s":
-			q := parts[1:]
+			q, format, err := splitFormatArg(parts[1:])
+			if err != nil {
+				fmt.Fprintln(w, err)
+				continue
+			}
+			tmpl = nil
+			if format != "" {
+				tmpl, err = parseResultTemplate(format)
+				if err != nil {
+					fmt.Fprintln(w, err)
+					continue
+				}
+			}
 			performSearch(q)
 			printResults()
 		case "o":
 			if len(parts) < 2 {
-				fmt.Println("Usage: o <match-number|path>")
+				fmt.Fprintln(w, "Usage: o <match-number|path>")
 				continue
 			}
 			arg := parts[1]
 			idx, err := strconv.Atoi(arg)
-			var tgt Match
+			var tgt SearchResult
 			if err == nil && idx >= 1 && idx <= len(matches) {
 				tgt = matches[idx-1]
 			} else {
@@ -634,28 +710,22 @@ func CmdSearchLoop() {
 					}
 				}
 				if !found {
-					fmt.Println("Path not in last results.")
+					fmt.Fprintln(w, "Path not in last results.")
 					continue
 				}
 			}
-			if tgt.Type == "file" {
-				fmt.Printf("Opening %s...\n", tgt.Path)
-				err := openFile(tgt.Path)
+			if tgt.Type == "file" || tgt.Type == "url" {
+				fmt.Fprintf(w, "Opening %s...\n", tgt.Path)
+				err := opener.Open(tgt.Path)
 				if err != nil {
-					fmt.Println("Error opening:", err)
+					fmt.Fprintln(w, "Error opening:", err)
 				}
-			} else if tgt.Type == "url" {
-				fmt.Println("Cannot open URLs from here.")
 			} else {
-				fmt.Printf("Entry is not a file.")
+				fmt.Fprintln(w, "Entry is not a file.")
 			}
 		default:
-			fmt.Println("Commands: s ... | o <n|path> | q")
+			fmt.Fprintln(w, "Commands: s ... | o <n|path> | q")
 		}
 	}
 }
 
-func openFile(path string) error {
-	cmd := exec.Command("open", path)
-	return cmd.Run()
-}
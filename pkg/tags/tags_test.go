@@ -1,9 +1,15 @@
 package tags
 
 import (
+    "bytes"
+    "errors"
+    "io"
     "os"
+    "path/filepath"
+    "strings"
     "testing"
     "github.com/tenzokai/filemac/pkg/catalog"
+    "github.com/tenzokai/filemac/pkg/utils"
 )
 
 func withTempCatalog(entries []catalog.CatEntry, testfunc func()) {
@@ -20,7 +26,7 @@ func TestCmdAddRemoveReplaceTag(t *testing.T) {
         {Name: "foo.txt", Tags: []string{"a", "b"}},
         {Name: "bar.txt", Tags: []string{"c"}},
     }, func() {
-        CmdAddTag("1", "c") // add new tag to entry 1
+        CmdAddTag(io.Discard, "1", "c") // add new tag to entry 1
         newCat, _ := catalog.LoadCatalog()
         found := false
         for _, tag := range newCat[0].Tags {
@@ -31,14 +37,14 @@ func TestCmdAddRemoveReplaceTag(t *testing.T) {
         if !found {
             t.Error("Tag not added")
         }
-        CmdRemoveTag("1", "a")
+        CmdRemoveTag(io.Discard, "1", "a")
         newCat, _ = catalog.LoadCatalog()
         for _, tag := range newCat[0].Tags {
             if tag == "a" {
                 t.Error("Tag not removed")
             }
         }
-        CmdReplaceTag("2", "c", "z")
+        CmdReplaceTag(io.Discard, "2", "c", "z")
         newCat, _ = catalog.LoadCatalog()
         tagOk := false
         for _, tag := range newCat[1].Tags {
@@ -51,3 +57,106 @@ func TestCmdAddRemoveReplaceTag(t *testing.T) {
         }
     })
 }
+
+func TestTagCommandErrors(t *testing.T) {
+    t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+    withTempCatalog([]catalog.CatEntry{
+        {Name: "foo.txt", Tags: []string{"a"}},
+    }, func() {
+        var out bytes.Buffer
+        cases := []struct {
+            err  error
+            want error
+        }{
+            {CmdAddTag(&out, "1", "a"), ErrTagExists},
+            {CmdAddTag(&out, "2", "b"), catalog.ErrBadIndex},
+            {CmdAddTag(&out, "1", " "), ErrEmptyTag},
+            {CmdAddTag(&out, "1", "@title=x"), catalog.ErrFieldTag},
+            {CmdReplaceTagAll(&out, "a", "@note=x"), catalog.ErrFieldTag},
+            {CmdRemoveTag(&out, "1", "b"), ErrTagNotFound},
+            {CmdReplaceTag(&out, "1", "b", "c"), ErrTagNotFound},
+        }
+        for i, c := range cases {
+            if !errors.Is(c.err, c.want) {
+                t.Errorf("case %d: got %v, want %v", i, c.err, c.want)
+            }
+        }
+        if out.Len() != 0 {
+            t.Errorf("failed commands wrote %q", out.String())
+        }
+        if err := CmdAddTag(&out, "1", "b"); err != nil || out.String() != "tag 'b' added to entry 1\n" {
+            t.Errorf("add = %v, %q", err, out.String())
+        }
+    })
+}
+
+func TestSearchResultTemplate(t *testing.T) {
+    terms, format, err := splitFormatArg([]string{"steuer", "-f", `{{.Path}}\t{{join .Tags ","}}`, "!privat"})
+    if err != nil {
+        t.Fatalf("splitFormatArg: %v", err)
+    }
+    if len(terms) != 2 || terms[0] != "steuer" || terms[1] != "!privat" {
+        t.Errorf("unexpected terms: %v", terms)
+    }
+    tmpl, err := parseResultTemplate(format)
+    if err != nil {
+        t.Fatalf("parseResultTemplate: %v", err)
+    }
+    var b strings.Builder
+    tmpl.Execute(&b, SearchResult{Path: "/docs/a.pdf", Tags: []string{"steuer", "2024"}})
+    if b.String() != "/docs/a.pdf\tsteuer,2024\n" {
+        t.Errorf("unexpected output: %q", b.String())
+    }
+    if _, _, err := splitFormatArg([]string{"x", "-f"}); err == nil {
+        t.Error("expected error for -f without template")
+    }
+}
+
+func TestSearchLoopResult(t *testing.T) {
+    // sl splits its input like the shell, so templates may hold blanks and quotes
+    words, err := utils.SplitWords(`s steuer -f '{{.Title}} | {{.Note}}'`)
+    if err != nil {
+        t.Fatal(err)
+    }
+    terms, format, err := splitFormatArg(words[1:])
+    if err != nil || len(terms) != 1 || format != "{{.Title}} | {{.Note}}" {
+        t.Fatalf("split = %q %q %v", terms, format, err)
+    }
+    t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+    tmpl, err := parseResultTemplate(format)
+    if err != nil {
+        t.Fatal(err)
+    }
+    var b strings.Builder
+    tmpl.Execute(&b, newResult(catalog.CatEntry{Name: "https://example.org", Type: "url", Title: "Beispiel", Note: "Vertrag"}, "/docs"))
+    if b.String() != "Beispiel | Vertrag\n" {
+        t.Errorf("unexpected output: %q", b.String())
+    }
+    r := newResult(catalog.CatEntry{Name: "a.pdf", Type: "file"}, "/docs")
+    if r.Path != "/docs/a.pdf" || r.Dir != "/docs" {
+        t.Errorf("unexpected result: %+v", r)
+    }
+}
+
+func TestResultTemplateConfigError(t *testing.T) {
+    home := t.TempDir()
+    t.Setenv("XDG_CONFIG_HOME", home)
+    os.MkdirAll(filepath.Join(home, "filemac"), 0755)
+    os.WriteFile(filepath.Join(home, "filemac", "config.toml"), []byte("[templates\n"), 0644)
+    if _, err := parseResultTemplate("{{.Path}}"); err == nil {
+        t.Error("expected config error")
+    }
+}
+
+func TestMatchEntryNote(t *testing.T) {
+    e := catalog.CatEntry{Name: "a.pdf", Tags: []string{"miete"}, Note: "Kündigungsfrist 3 Monate"}
+    if !MatchEntry([]string{"miete", "note:~KÜNDIGUNG"}, e) {
+        t.Error("expected note substring match")
+    }
+    if MatchEntry([]string{"!note:~kündigung"}, e) {
+        t.Error("negated note term should exclude entry")
+    }
+    if MatchEntry([]string{"note:kündigung"}, e) {
+        t.Error("note: without ~ should require the whole note")
+    }
+}
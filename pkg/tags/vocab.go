@@ -0,0 +1,65 @@
+package tags
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/tenzokai/filemac/pkg/catalog"
+	"github.com/tenzokai/filemac/pkg/config"
+)
+
+// allowTag applies the [tags] strictness setting to a tag about to be given
+// to entries. It returns ErrUnknownTag if the tag may not be used and writes
+// a note to w if it is new. The vocabulary is the [tags] vocabulary list, or
+// the tags already in use when that is empty.
+func allowTag(w io.Writer, tag string) error {
+	cfg, err := config.Load()
+	if err != nil {
+		fmt.Fprintln(w, "config error:", err)
+		return nil
+	}
+	if cfg.Tags.Strictness == "off" {
+		return nil
+	}
+	known := cfg.Tags.Vocabulary
+	if len(known) == 0 {
+		known = tagsInUse()
+	}
+	for _, t := range known {
+		if t == tag {
+			return nil
+		}
+	}
+	if cfg.Tags.Strictness == "strict" {
+		return fmt.Errorf("%w: '%s' ([tags] strictness = \"strict\")", ErrUnknownTag, tag)
+	}
+	fmt.Fprintf(w, "note: '%s' is not in the vocabulary yet\n", tag)
+	return nil
+}
+
+// tagsInUse returns the tags of the catalogs visible from the current folder.
+func tagsInUse() []string {
+	cwd, _ := os.Getwd()
+	sources, err := catalog.ResolveSources(cwd)
+	if err != nil {
+		return nil
+	}
+	var tags []string
+	seen := map[string]bool{}
+	for _, src := range sources {
+		entries, err := catalog.LoadCatalogAt(src.File)
+		if err != nil {
+			continue
+		}
+		for _, e := range entries {
+			for _, t := range e.Tags {
+				if !seen[t] {
+					seen[t] = true
+					tags = append(tags, t)
+				}
+			}
+		}
+	}
+	return tags
+}
@@ -0,0 +1,179 @@
+// Package textract extracts plain text from the document formats filemac
+// indexes: plain text, Markdown, HTML, DOCX/ODT and text-layer PDFs.
+package textract
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"errors"
+	"html"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/tenzokai/filemac/pkg/pdf"
+)
+
+// ErrUnsupported is returned for file types without an extractor.
+var ErrUnsupported = errors.New("unsupported file type")
+
+// maxText bounds how much of a plain text file is read.
+const maxText = 8 << 20
+
+// Supported reports whether Extract handles the file's extension.
+func Supported(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".txt", ".md", ".markdown", ".html", ".htm", ".docx", ".odt", ".pdf":
+		return true
+	}
+	return false
+}
+
+// Extract returns the text content of the file at path.
+func Extract(path string) (string, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".txt", ".md", ".markdown":
+		return readText(path)
+	case ".html", ".htm":
+		s, err := readText(path)
+		if err != nil {
+			return "", err
+		}
+		return StripHTML(s), nil
+	case ".docx":
+		return zipXML(path, "word/document.xml")
+	case ".odt":
+		return zipXML(path, "content.xml")
+	case ".pdf":
+		r, err := pdf.Open(path)
+		if err != nil {
+			return "", err
+		}
+		return r.Text()
+	}
+	return "", ErrUnsupported
+}
+
+func readText(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	data, err := io.ReadAll(io.LimitReader(f, maxText))
+	if err != nil {
+		return "", err
+	}
+	if !utf8.Valid(data) {
+		// assume Latin-1, common for older German text files
+		runes := make([]rune, len(data))
+		for i, b := range data {
+			runes[i] = rune(b)
+		}
+		return string(runes), nil
+	}
+	return string(data), nil
+}
+
+// blockTags end a line when converting HTML to text.
+var blockTags = map[string]bool{
+	"p": true, "div": true, "br": true, "li": true, "tr": true, "h1": true, "h2": true,
+	"h3": true, "h4": true, "h5": true, "h6": true, "table": true, "section": true, "article": true,
+}
+
+// StripHTML converts an HTML document to plain text, dropping scripts and
+// styles and decoding entities.
+func StripHTML(s string) string {
+	var b strings.Builder
+	lower := strings.ToLower(s)
+	for i := 0; i < len(s); {
+		if s[i] != '<' {
+			j := strings.IndexByte(s[i:], '<')
+			if j < 0 {
+				j = len(s) - i
+			}
+			b.WriteString(strings.ReplaceAll(html.UnescapeString(s[i:i+j]), "\u00a0", " "))
+			i += j
+			continue
+		}
+		end := strings.IndexByte(s[i:], '>')
+		if end < 0 {
+			break
+		}
+		raw := lower[i+1 : i+end]
+		i += end + 1
+		closing := strings.HasPrefix(raw, "/")
+		fields := strings.Fields(strings.TrimLeft(raw, "/!"))
+		if len(fields) == 0 {
+			continue
+		}
+		tag := strings.TrimSuffix(fields[0], "/")
+		if (tag == "script" || tag == "style") && !closing {
+			skip := strings.Index(lower[i:], "</"+tag)
+			if skip < 0 {
+				break
+			}
+			i += skip
+			continue
+		}
+		if blockTags[tag] && b.Len() > 0 && !strings.HasSuffix(b.String(), "\n") {
+			b.WriteByte('\n')
+		}
+	}
+	return b.String()
+}
+
+// zipXML extracts the character data of one XML member of a zip container.
+func zipXML(path, member string) (string, error) {
+	zr, err := zip.OpenReader(path)
+	if err != nil {
+		return "", err
+	}
+	defer zr.Close()
+	for _, f := range zr.File {
+		if f.Name != member {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return "", err
+		}
+		defer rc.Close()
+		return xmlText(io.LimitReader(rc, maxText))
+	}
+	return "", errors.New(member + " not found in " + filepath.Base(path))
+}
+
+// xmlText returns the character data of an office document, with paragraph
+// ends as newlines and tab elements as tabs.
+func xmlText(r io.Reader) (string, error) {
+	var b strings.Builder
+	dec := xml.NewDecoder(r)
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			return b.String(), nil
+		} else if err != nil {
+			return b.String(), err
+		}
+		switch t := tok.(type) {
+		case xml.CharData:
+			b.Write(t)
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "tab":
+				b.WriteByte('\t')
+			case "br", "line-break":
+				b.WriteByte('\n')
+			case "s":
+				b.WriteByte(' ')
+			}
+		case xml.EndElement:
+			if t.Name.Local == "p" || t.Name.Local == "h" {
+				b.WriteByte('\n')
+			}
+		}
+	}
+}
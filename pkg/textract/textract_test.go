@@ -0,0 +1,41 @@
+package textract
+
+import (
+    "archive/zip"
+    "os"
+    "path/filepath"
+    "strings"
+    "testing"
+)
+
+func TestStripHTML(t *testing.T) {
+    got := StripHTML(`<html><head><style>p{color:red}</style><script>var x="<p>";</script></head>` +
+        `<body><p>Sehr geehrte&nbsp;Damen</p><p>K&uuml;ndigung</p></body></html>`)
+    if strings.Contains(got, "color") || strings.Contains(got, "var x") {
+        t.Errorf("script/style not removed: %q", got)
+    }
+    if !strings.Contains(got, "Sehr geehrte Damen\nKündigung") {
+        t.Errorf("unexpected text: %q", got)
+    }
+}
+
+func TestExtractDocx(t *testing.T) {
+    path := filepath.Join(t.TempDir(), "brief.docx")
+    f, _ := os.Create(path)
+    zw := zip.NewWriter(f)
+    w, _ := zw.Create("word/document.xml")
+    w.Write([]byte(`<?xml version="1.0"?><w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main">` +
+        `<w:body><w:p><w:r><w:t>Erste</w:t></w:r><w:r><w:tab/><w:t>Zeile</w:t></w:r></w:p><w:p><w:r><w:t>Zweite</w:t></w:r></w:p></w:body></w:document>`))
+    zw.Close()
+    f.Close()
+    got, err := Extract(path)
+    if err != nil {
+        t.Fatalf("Extract: %v", err)
+    }
+    if got != "Erste\tZeile\nZweite\n" {
+        t.Errorf("unexpected text: %q", got)
+    }
+    if _, err := Extract("scan.tiff"); err != ErrUnsupported {
+        t.Errorf("expected ErrUnsupported, got %v", err)
+    }
+}
@@ -3,8 +3,14 @@ package utils
 
 import (
     "fmt"
+    "io"
     "os"
+    "path/filepath"
     "sort"
+    "strconv"
+    "strings"
+
+    "github.com/tenzokai/filemac/pkg/table"
 )
 
 
@@ -60,52 +66,64 @@ func AbsPath(path string) string {
     return joined // fallback
 }
 
+// ExpandHome replaces a leading ~ with the user's home directory.
+func ExpandHome(path string) string {
+    if path != "~" && !strings.HasPrefix(path, "~/") {
+        return path
+    }
+    home, err := os.UserHomeDir()
+    if err != nil {
+        return path
+    }
+    return filepath.Join(home, path[1:])
+}
+
 // isAbs checks if a path is absolute.
 func isAbs(path string) bool {
     return len(path) > 0 && (path[0] == '/' || path[0] == '~')
 }
 
 // CmdCd changes into the specified directory, creates it if missing.
-func CmdCd(path string) {
+func CmdCd(w io.Writer, path string) error {
     if path == "" {
-        fmt.Println("CmdCd: No path given!")
-        return
+        return fmt.Errorf("cd: no path given")
     }
     err := MkdirIfMissing(path)
     if err != nil {
-        fmt.Printf("CmdCd: Error creating directory: %v\n", err)
-        return
+        return fmt.Errorf("cd: error creating directory: %w", err)
     }
     err = os.Chdir(path)
     if err != nil {
-        fmt.Printf("CmdCd: Failed to change directory: %v\n", err)
-        return
+        return fmt.Errorf("cd: %w", err)
     }
-    fmt.Printf("Changed directory to: %s\n", path)
+    fmt.Fprintf(w, "Changed directory to: %s\n", path)
+    return nil
 }
 
 // CmdLs lists visible (non-dotfile) files/dirs in the current directory.
-func CmdLs() {
+func CmdLs(w io.Writer) error {
     cwd, err := os.Getwd()
     if err != nil {
-        fmt.Printf("CmdLs: error getting current dir: %v\n", err)
-        return
+        return fmt.Errorf("ls: error getting current dir: %w", err)
     }
     names, err := ListVisibleFiles(cwd)
     if err != nil {
-        fmt.Printf("CmdLs: error reading directory: %v\n", err)
-        return
+        return fmt.Errorf("ls: error reading directory: %w", err)
     }
-    fmt.Printf("num    | type  | name\n")
-    n := 1
-    for _, name := range names {
-        t := "file"
+    t := table.New(
+        table.Column{Title: "num", Min: 6},
+        table.Column{Title: "type", Min: 5},
+        table.Column{Title: "name", Min: 16, Middle: true},
+    )
+    for n, name := range names {
+        typ := "file"
         if isURL(name) {
-            t = "url"
+            typ = "url"
         }
-        fmt.Printf("%-6d | %-5s | %s\n", n, t, name)
-        n++
+        t.Add(strconv.Itoa(n+1), typ, name)
     }
+    t.Render(w)
+    return nil
 }
 
 // isURL checks if name looks like a URL (for ls output)
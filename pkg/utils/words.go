@@ -0,0 +1,53 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SplitWords breaks a command line into words. Words are separated by
+// blanks; single and double quotes group words and a backslash escapes the
+// next character outside single quotes.
+func SplitWords(line string) ([]string, error) {
+	var words []string
+	var b strings.Builder
+	inWord := false
+	var quote rune
+	escaped := false
+	for _, r := range line {
+		switch {
+		case escaped:
+			b.WriteRune(r)
+			escaped = false
+		case r == '\\' && quote != '\'':
+			escaped, inWord = true, true
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				b.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote, inWord = r, true
+		case r == ' ' || r == '\t':
+			if inWord {
+				words = append(words, b.String())
+				b.Reset()
+				inWord = false
+			}
+		default:
+			b.WriteRune(r)
+			inWord = true
+		}
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated %c quote", quote)
+	}
+	if escaped {
+		return nil, fmt.Errorf("line ends with a backslash")
+	}
+	if inWord {
+		words = append(words, b.String())
+	}
+	return words, nil
+}
@@ -0,0 +1,424 @@
+// Package walkthrough implements the interactive tag fixer (w).
+package walkthrough
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/peterh/liner"
+	"github.com/tenzokai/filemac/pkg/catalog"
+	"github.com/tenzokai/filemac/pkg/classify"
+	"github.com/tenzokai/filemac/pkg/index"
+	"github.com/tenzokai/filemac/pkg/opener"
+	"github.com/tenzokai/filemac/pkg/tags"
+)
+
+// maxPicks is the number of numbered tag picks shown per entry.
+const maxPicks = 9
+
+// minSuggestion is the lowest classifier confidence offered as a pick.
+const minSuggestion = 0.2
+
+const help = `Edit the tags (separated by commas or spaces) and press enter, or:
+    +tag -tag    add/remove single tags     1 3      add numbered picks
+    b            back to previous entry     j <num>  jump to entry
+    o            open the file              stop     end the walkthrough
+    (enter on unchanged tags skips the entry)`
+
+// ResumeFilename stores the position of an unfinished walkthrough next to
+// the catalog or hub it belongs to.
+var ResumeFilename = ".catwalk"
+
+// walkCatalog is a catalog taking part in a walkthrough.
+type walkCatalog struct {
+	catalog.Source
+	entries []catalog.CatEntry
+	index   *index.Index
+}
+
+// item is one entry of a walkthrough.
+type item struct{ cat, idx int }
+
+// CmdWalkthrough lets the user edit the tags of catalog entries one by one:
+//
+//	w [<num>]     all entries, starting at entry num (a number beyond the
+//	              catalog is a query, e.g. the tag 2024)
+//	w -new        only entries without tags
+//	w <query...>  entries matching a search (also across .catlink)
+//	w -resume     continue the last unfinished walkthrough here
+func CmdWalkthrough(w io.Writer, args []string) error {
+	cwd, _ := os.Getwd()
+	start, resumeKey := 0, ""
+	if len(args) == 1 && args[0] == "-resume" {
+		var err error
+		if args, resumeKey, err = loadResume(cwd); err != nil {
+			return fmt.Errorf("nothing to resume: %w", err)
+		}
+	} else if len(args) == 1 {
+		if n, err := strconv.Atoi(args[0]); err == nil {
+			if _, all, err := selectItems(cwd, nil); err == nil && n >= 1 && n <= len(all) {
+				start = n - 1
+				args = nil
+			}
+		}
+	}
+	cats, items, err := selectItems(cwd, args)
+	if err != nil {
+		return err
+	}
+	for k, it := range items {
+		if resumeKey != "" && itemKey(cats, it) == resumeKey {
+			start = k
+		}
+	}
+	if start < 0 || start >= len(items) {
+		start = 0
+	}
+	walk(w, cwd, args, cats, items, start)
+	return nil
+}
+
+// selectItems loads the catalogs visible from dir and returns the entries
+// the walkthrough arguments select.
+func selectItems(dir string, args []string) ([]*walkCatalog, []item, error) {
+	sources, err := catalog.ResolveSources(dir)
+	if err != nil {
+		return nil, nil, err
+	}
+	var cats []*walkCatalog
+	var items []item
+	for c, src := range sources {
+		entries, err := catalog.LoadCatalogAt(src.File)
+		if err != nil {
+			return nil, nil, err
+		}
+		ix, err := index.Load(src.Dir)
+		if err != nil {
+			ix = &index.Index{}
+		}
+		cats = append(cats, &walkCatalog{Source: src, entries: entries, index: ix})
+		for i, e := range entries {
+			switch {
+			case len(args) == 1 && args[0] == "-new":
+				if len(e.Tags) > 0 {
+					continue
+				}
+			case len(args) > 0:
+				if !tags.MatchEntry(args, e) {
+					continue
+				}
+			}
+			items = append(items, item{c, i})
+		}
+	}
+	return cats, items, nil
+}
+
+func walk(w io.Writer, cwd string, args []string, cats []*walkCatalog, items []item, i int) {
+	if len(items) == 0 {
+		fmt.Fprintln(w, "No entries to walk through.")
+		return
+	}
+	var all []catalog.CatEntry
+	for _, c := range cats {
+		all = append(all, c.entries...)
+	}
+	// suggestions are optional, errors just leave them out
+	model, err := classify.ForDir(cwd)
+	if err != nil {
+		model = nil
+	}
+
+	line := liner.NewLiner()
+	defer line.Close()
+	line.SetCtrlCAborts(true)
+	line.SetWordCompleter(tagCompleter(func() []string { return allTags(all) }))
+
+	fmt.Fprintln(w, help)
+	changed := map[item]bool{}
+	skipped := map[item]bool{}
+	var trail []int // visited positions, for 'b'
+	for i < len(items) {
+		it := items[i]
+		c := cats[it.cat]
+		e := &c.entries[it.idx]
+		picks := pickList(all, *e, model, c.index.Docs[e.Name])
+		where := ""
+		if len(cats) > 1 || c.Dir != cwd {
+			where = " (" + c.Dir + ")"
+		}
+		fmt.Fprintf(w, "\nEntry %d / %d: #%d %s%s\n", i+1, len(items), it.idx+1, e.Name, where)
+		if e.Note != "" {
+			fmt.Fprintf(w, "  Note: %s\n", e.Note)
+		}
+		if len(picks) > 0 {
+			fmt.Fprint(w, "  Picks:")
+			for k, p := range picks {
+				fmt.Fprintf(w, " [%d] %s", k+1, p)
+			}
+			fmt.Fprintln(w)
+		}
+		current := strings.Join(e.Tags, ", ")
+		input, err := line.PromptWithSuggestion("tags> ", current, -1)
+		if err != nil {
+			fmt.Fprintln(w)
+			input = "stop"
+		}
+		input = strings.TrimSpace(input)
+		if input != "" && input != current {
+			line.AppendHistory(input)
+		}
+		fields := strings.Fields(input)
+		switch {
+		case input == "stop" || input == "q":
+			if err := saveResume(cwd, args, itemKey(cats, it)); err != nil {
+				fmt.Fprintln(w, "could not save position:", err)
+			}
+			fmt.Fprintf(w, "Stopped at entry %d / %d, continue with 'w -resume'.\n", i+1, len(items))
+			summary(w, changed, skipped)
+			return
+		case input == "o":
+			if err := opener.Open(catalog.EntryPath(c.Dir, *e)); err != nil {
+				fmt.Fprintln(w, "Error opening:", err)
+			}
+			continue
+		case input == "b":
+			if len(trail) == 0 {
+				fmt.Fprintln(w, "Already at the first entry.")
+				continue
+			}
+			i = trail[len(trail)-1]
+			trail = trail[:len(trail)-1]
+			continue
+		case len(fields) == 2 && fields[0] == "j":
+			n, err := strconv.Atoi(fields[1])
+			if err != nil || n < 1 || n > len(items) {
+				fmt.Fprintf(w, "jump target must be between 1 and %d\n", len(items))
+				continue
+			}
+			trail = append(trail, i)
+			i = n - 1
+			continue
+		case input == "" || input == current:
+			if !changed[it] {
+				skipped[it] = true
+			}
+		default:
+			old, newTags, err := c.apply(e.Name, input, picks)
+			if err != nil {
+				fmt.Fprintf(w, "Error saving: %v\n", err)
+				continue
+			}
+			e.Tags = newTags
+			if !sameTags(newTags, old) {
+				fmt.Fprintf(w, "Saved: %s\n", strings.Join(newTags, ", "))
+				changed[it] = true
+				delete(skipped, it)
+			}
+		}
+		trail = append(trail, i)
+		i++
+	}
+	os.Remove(filepath.Join(cwd, ResumeFilename))
+	fmt.Fprintln(w, "Finished walkthrough.")
+	summary(w, changed, skipped)
+}
+
+// apply applies the input for the entry called name and saves the
+// catalog. The catalog is loaded again under the catalog lock, so changes
+// saved since the walkthrough started (e.g. by the watcher) are kept and
+// the input applies to the entry's current tags. apply returns the tags
+// before and after.
+func (c *walkCatalog) apply(name, input string, picks []string) ([]string, []string, error) {
+	catalog.Lock()
+	defer catalog.Unlock()
+	entries, err := catalog.LoadCatalogAt(c.File)
+	if err != nil {
+		return nil, nil, err
+	}
+	for i := range entries {
+		if entries[i].Name != name {
+			continue
+		}
+		old := entries[i].Tags
+		newTags := ApplyInput(old, input, picks)
+		if sameTags(newTags, old) {
+			return old, old, nil
+		}
+		entries[i].Tags = newTags
+		if err := catalog.SaveCatalogAt(c.File, entries); err != nil {
+			return nil, nil, err
+		}
+		return old, newTags, nil
+	}
+	return nil, nil, fmt.Errorf("%s is no longer in %s", name, c.File)
+}
+
+// itemKey identifies an entry independent of its position.
+func itemKey(cats []*walkCatalog, it item) string {
+	return cats[it.cat].Dir + "\t" + cats[it.cat].entries[it.idx].Name
+}
+
+// saveResume records the walkthrough arguments and the current entry.
+func saveResume(dir string, args []string, key string) error {
+	data := strings.Join(args, "\t") + "\n" + key + "\n"
+	return os.WriteFile(filepath.Join(dir, ResumeFilename), []byte(data), 0644)
+}
+
+// loadResume returns the arguments and entry saved by saveResume.
+func loadResume(dir string) ([]string, string, error) {
+	data, err := os.ReadFile(filepath.Join(dir, ResumeFilename))
+	if err != nil {
+		return nil, "", err
+	}
+	lines := strings.SplitN(strings.TrimRight(string(data), "\n"), "\n", 2)
+	if len(lines) != 2 {
+		return nil, "", fmt.Errorf("malformed %s", ResumeFilename)
+	}
+	var args []string
+	if lines[0] != "" {
+		args = strings.Split(lines[0], "\t")
+	}
+	return args, lines[1], nil
+}
+
+func summary(w io.Writer, changed, skipped map[item]bool) {
+	fmt.Fprintf(w, "Changed %d, skipped %d entries.\n", len(changed), len(skipped))
+}
+
+// ApplyInput returns the tags resulting from the user's input for an entry
+// with the given tags. Words are separated by commas or spaces; +tag adds,
+// -tag removes and a number adds that pick. If the input contains plain
+// tags it replaces the tag list, otherwise the changes apply to tags.
+// Words catalog.CheckTag rejects are dropped.
+func ApplyInput(tags []string, input string, picks []string) []string {
+	words := strings.FieldsFunc(input, func(r rune) bool { return r == ',' || r == ' ' || r == '\t' })
+	isPick := func(w string) bool {
+		n, err := strconv.Atoi(w)
+		return err == nil && n >= 1 && n <= len(picks)
+	}
+	var out []string
+	replace := false
+	for _, w := range words {
+		if w[0] != '+' && w[0] != '-' && !isPick(w) {
+			replace = true
+		}
+	}
+	if !replace {
+		out = append(out, tags...)
+	}
+	for _, w := range words {
+		switch {
+		case isPick(w):
+			n, _ := strconv.Atoi(w)
+			w = picks[n-1]
+		case w[0] == '+':
+			w = w[1:]
+		case w[0] == '-':
+			kept := out[:0]
+			for _, t := range out {
+				if t != w[1:] {
+					kept = append(kept, t)
+				}
+			}
+			out = kept
+			continue
+		}
+		w = strings.ReplaceAll(w, "*", "")
+		if w != "" && catalog.CheckTag(w) == nil && !contains(out, w) {
+			out = append(out, w)
+		}
+	}
+	return out
+}
+
+// pickList offers classifier suggestions first, then the most used tags of
+// the catalog, leaving out tags the entry already has.
+func pickList(entries []catalog.CatEntry, e catalog.CatEntry, model *classify.Model, doc *index.Doc) []string {
+	var picks []string
+	add := func(t string) {
+		if len(picks) < maxPicks && !contains(e.Tags, t) && !contains(picks, t) {
+			picks = append(picks, t)
+		}
+	}
+	if model != nil {
+		for _, p := range model.Predict(classify.Features(e.Name, doc)) {
+			if p.Confidence < minSuggestion || len(picks) >= 3 {
+				break
+			}
+			add(p.Tag)
+		}
+	}
+	for _, t := range allTags(entries) {
+		add(t)
+	}
+	return picks
+}
+
+// allTags returns the catalog's tags, most used first.
+func allTags(entries []catalog.CatEntry) []string {
+	count := map[string]int{}
+	for _, e := range entries {
+		for _, t := range e.Tags {
+			count[t]++
+		}
+	}
+	tags := make([]string, 0, len(count))
+	for t := range count {
+		tags = append(tags, t)
+	}
+	sort.Slice(tags, func(i, j int) bool {
+		if count[tags[i]] != count[tags[j]] {
+			return count[tags[i]] > count[tags[j]]
+		}
+		return tags[i] < tags[j]
+	})
+	return tags
+}
+
+// tagCompleter completes the tag under the cursor, after an optional + or -.
+func tagCompleter(tags func() []string) liner.WordCompleter {
+	return func(line string, pos int) (string, []string, string) {
+		r := []rune(line) // pos counts runes
+		head := string(r[:pos])
+		start := strings.LastIndexAny(head, ", \t") + 1
+		if start < len(head) && (head[start] == '+' || head[start] == '-') {
+			start++
+		}
+		prefix := head[start:]
+		var out []string
+		for _, t := range tags() {
+			if strings.HasPrefix(t, prefix) {
+				out = append(out, t)
+			}
+		}
+		sort.Strings(out)
+		return head[:start], out, string(r[pos:])
+	}
+}
+
+func contains(tags []string, tag string) bool {
+	for _, t := range tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+func sameTags(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,146 @@
+package walkthrough
+
+import (
+    "bytes"
+    "fmt"
+    "io"
+    "os"
+    "strings"
+    "testing"
+
+    "github.com/tenzokai/filemac/internal/testutil"
+    "github.com/tenzokai/filemac/pkg/catalog"
+    "github.com/tenzokai/filemac/pkg/watch"
+)
+
+func TestApplyInput(t *testing.T) {
+    tags := []string{"steuer", "2023"}
+    picks := []string{"arbeit", "bank"}
+    for input, want := range map[string]string{
+        "+lohn -2023":          "steuer,lohn",
+        "2 1":                  "steuer,2023,bank,arbeit",
+        "steuer, 2024 +x":      "steuer,2024,x",
+        "steuer, 2023 -2023 1": "steuer,arbeit",
+        "-steuer -2023":        "",
+        "a*b":                  "ab",
+        "+@note=x +y":          "steuer,2023,y",
+    } {
+        got := strings.Join(ApplyInput(tags, input, picks), ",")
+        if got != want {
+            t.Errorf("ApplyInput(%q) = %q, want %q", input, got, want)
+        }
+    }
+}
+
+func TestTagCompleter(t *testing.T) {
+    c := tagCompleter(func() []string { return []string{"steuer", "stadt", "übung"} })
+    head, got, tail := c("x, +st y", 6)
+    if head != "x, +" || strings.Join(got, ",") != "stadt,steuer" || tail != " y" {
+        t.Errorf("got %q %v %q", head, got, tail)
+    }
+    head, got, _ = c("ä, üb", 5)
+    if head != "ä, " || len(got) != 1 {
+        t.Errorf("got %q %v", head, got)
+    }
+}
+
+func TestScopedWalkthroughResume(t *testing.T) {
+    dir := t.TempDir()
+    t.Chdir(dir)
+    catalog.SaveCatalogAt(".cat", []catalog.CatEntry{
+        {Name: "a.pdf", Type: "file", Tags: []string{"steuer"}},
+        {Name: "b.pdf", Type: "file"},
+        {Name: "c.pdf", Type: "file"},
+        {Name: "d.pdf", Type: "file"},
+    })
+    cats, items, err := selectItems(dir, []string{"-new"})
+    if err != nil || len(items) != 3 || itemKey(cats, items[0]) != dir+"\tb.pdf" {
+        t.Fatalf("selectItems -new: %v %v", items, err)
+    }
+    if _, items, _ := selectItems(dir, []string{"steuer"}); len(items) != 1 {
+        t.Errorf("query selected %d entries", len(items))
+    }
+
+    // tag b, skip c, stop at d
+    testutil.WithStdin(t, "+bank\n\nstop\n", func() { CmdWalkthrough(io.Discard, []string{"-new"}) })
+    entries, _ := catalog.LoadCatalog()
+    if strings.Join(entries[1].Tags, ",") != "bank" || len(entries[2].Tags) != 0 {
+        t.Fatalf("unexpected entries %+v", entries)
+    }
+    args, key, err := loadResume(dir)
+    if err != nil || strings.Join(args, " ") != "-new" || key != dir+"\td.pdf" {
+        t.Fatalf("resume state: %v %q %v", args, key, err)
+    }
+    testutil.WithStdin(t, "x\n", func() { CmdWalkthrough(io.Discard, []string{"-resume"}) })
+    entries, _ = catalog.LoadCatalog()
+    if strings.Join(entries[3].Tags, ",") != "x" || len(entries[2].Tags) != 0 {
+        t.Errorf("resume did not continue at d.pdf: %+v", entries)
+    }
+    if _, err := os.Stat(ResumeFilename); !os.IsNotExist(err) {
+        t.Error("resume file not removed after finishing")
+    }
+}
+
+func TestNumericArgument(t *testing.T) {
+    t.Chdir(t.TempDir())
+    catalog.SaveCatalogAt(".cat", []catalog.CatEntry{
+        {Name: "a.pdf", Type: "file"},
+        {Name: "b.pdf", Type: "file", Tags: []string{"2024"}},
+        {Name: "c.pdf", Type: "file"},
+    })
+    for arg, want := range map[string]string{
+        "2":    "Entry 2 / 3: #2 b.pdf",
+        "2024": "Entry 1 / 1: #2 b.pdf",
+        "0":    "No entries",
+    } {
+        var out bytes.Buffer
+        testutil.WithStdin(t, "stop\n", func() { CmdWalkthrough(&out, []string{arg}) })
+        if !strings.Contains(out.String(), want) {
+            t.Errorf("w %s: want %q in output:\n%s", arg, want, out.String())
+        }
+    }
+}
+
+func TestSaveKeepsWatcherChanges(t *testing.T) {
+    dir := t.TempDir()
+    t.Chdir(dir)
+    t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+    os.WriteFile("a.pdf", []byte("a"), 0644)
+    os.WriteFile("b.pdf", []byte("b"), 0644)
+    catalog.SaveCatalogAt(".cat", []catalog.CatEntry{{Name: "a.pdf", Type: "file"}, {Name: "b.pdf", Type: "file"}})
+    cats, _, err := selectItems(dir, nil)
+    if err != nil {
+        t.Fatal(err)
+    }
+    watcher := watch.New([]catalog.Source{cats[0].Source}, false)
+    watcher.Notify = func(string) {}
+
+    // the watcher adds a file after the walkthrough loaded the catalog
+    os.WriteFile("c.pdf", []byte("c"), 0644)
+    if _, err := watcher.Sync(); err != nil {
+        t.Fatal(err)
+    }
+    if _, tags, err := cats[0].apply("a.pdf", "+steuer", nil); err != nil || strings.Join(tags, ",") != "steuer" {
+        t.Fatalf("apply = %v, %v", tags, err)
+    }
+
+    // and keeps syncing while answers are saved
+    done := make(chan bool)
+    go func() {
+        for k := 0; k < 20; k++ {
+            os.WriteFile(fmt.Sprintf("n%d.pdf", k), nil, 0644)
+            watcher.Sync()
+        }
+        done <- true
+    }()
+    for k := 0; k < 20; k++ {
+        if _, _, err := cats[0].apply("b.pdf", fmt.Sprintf("+t%d", k), nil); err != nil {
+            t.Fatal(err)
+        }
+    }
+    <-done
+    entries, _ := catalog.LoadCatalog()
+    if len(entries) != 23 || strings.Join(entries[0].Tags, ",") != "steuer" || len(entries[1].Tags) != 20 {
+        t.Errorf("changes lost: %d entries, %+v %+v", len(entries), entries[0], entries[1])
+    }
+}
@@ -0,0 +1,371 @@
+// Package watch keeps the catalogs of the current folder and its linked
+// folders in sync with the file system while the shell is running.
+//
+// Changes are noticed through inotify on Linux and by polling elsewhere (or
+// when inotify is unavailable). New files are added with the tags of the
+// folder's .catrules and vanished files are removed and tombstoned (see
+// 'trash ls'). With "renames = true" in the [watch] config table renamed or
+// moved files keep their tags. Like init, the watcher leaves a catalog
+// alone when more than [init] confirm_percent of its entries would go.
+package watch
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tenzokai/filemac/pkg/catalog"
+	"github.com/tenzokai/filemac/pkg/config"
+	"github.com/tenzokai/filemac/pkg/rules"
+)
+
+// settle is how long the watcher waits after a change notification before
+// syncing, so files being copied are complete.
+const settle = 300 * time.Millisecond
+
+// Watcher syncs a set of catalog folders.
+type Watcher struct {
+	Sources []catalog.Source
+	Renames bool
+	// Notify receives a message for every change made to a catalog.
+	Notify func(msg string)
+
+	// seen remembers the files of the last sync to recognize renames.
+	seen map[string]map[string]os.FileInfo
+	// held lists the folders whose sync is skipped for too many removals,
+	// so the warning is shown once.
+	held map[string]bool
+	stop chan struct{}
+	done chan struct{}
+	mode string
+}
+
+// New returns a watcher for the catalogs in sources.
+func New(sources []catalog.Source, renames bool) *Watcher {
+	return &Watcher{
+		Sources: sources,
+		Renames: renames,
+		Notify:  func(msg string) { fmt.Println(msg) },
+		seen:    map[string]map[string]os.FileInfo{},
+		held:    map[string]bool{},
+	}
+}
+
+type fileRef struct {
+	src  int
+	name string
+	fi   os.FileInfo
+}
+
+// Sync brings every catalog up to date with its folder and returns the
+// number of catalogs that were changed. If a folder lost more than [init]
+// confirm_percent of its entries (not mounted, half synced) nothing is
+// synced and a warning is sent to Notify instead.
+func (w *Watcher) Sync() (int, error) {
+	catalog.Lock()
+	defer catalog.Unlock()
+	cats := make([][]catalog.CatEntry, len(w.Sources))
+	gone := make([][]catalog.CatEntry, len(w.Sources))
+	dirty := make([]bool, len(w.Sources))
+	limits := make([]int, len(w.Sources))
+	var added, vanished []fileRef
+	current := map[string]map[string]os.FileInfo{}
+	for s, src := range w.Sources {
+		cfg, err := config.LoadDir(src.Dir)
+		if err != nil {
+			return 0, err
+		}
+		limits[s] = cfg.Init.ConfirmPercent
+		files, err := listFiles(src.Dir, cfg)
+		if err != nil {
+			return 0, err
+		}
+		current[src.Dir] = files
+		entries, err := catalog.LoadCatalogAt(src.File)
+		if err != nil {
+			return 0, err
+		}
+		cats[s] = entries
+		known := map[string]bool{}
+		for _, e := range entries {
+			known[e.Name] = true
+			if _, ok := files[e.Name]; !ok && e.Type == "file" {
+				vanished = append(vanished, fileRef{s, e.Name, w.seen[src.Dir][e.Name]})
+			}
+		}
+		for name, fi := range files {
+			if !known[name] {
+				added = append(added, fileRef{s, name, fi})
+			}
+		}
+	}
+
+	moved := map[fileRef]bool{}
+	var renames [][2]fileRef
+	for _, a := range added {
+		if from, ok := w.renamedFrom(a, vanished, moved); ok {
+			moved[from], moved[a] = true, true
+			renames = append(renames, [2]fileRef{from, a})
+		}
+	}
+	if w.tooManyRemovals(cats, vanished, moved, limits) {
+		return 0, nil
+	}
+	w.seen = current
+
+	for _, r := range renames {
+		from, a := r[0], r[1]
+		e := take(&cats[from.src], from.name)
+		e.Name = a.name
+		e.Raw = ""
+		cats[a.src] = append(cats[a.src], e)
+		dirty[from.src], dirty[a.src] = true, true
+		if from.src == a.src {
+			w.Notify(fmt.Sprintf("watch: renamed %s -> %s", from.name, a.name))
+		} else {
+			w.Notify(fmt.Sprintf("watch: moved %s -> %s", filepath.Join(w.Sources[from.src].Dir, from.name), filepath.Join(w.Sources[a.src].Dir, a.name)))
+		}
+	}
+	for _, v := range vanished {
+		if moved[v] {
+			continue
+		}
+		gone[v.src] = append(gone[v.src], take(&cats[v.src], v.name))
+		dirty[v.src] = true
+		w.Notify(fmt.Sprintf("watch: removed %s", filepath.Join(w.Sources[v.src].Dir, v.name)))
+	}
+	for _, a := range added {
+		if moved[a] {
+			continue
+		}
+		dir := w.Sources[a.src].Dir
+		set, err := rules.Load(dir)
+		if err != nil {
+			w.Notify(fmt.Sprintf("watch: %v", err))
+			set = &rules.Set{}
+		}
+		e := catalog.CatEntry{Name: a.name, Type: "file"}
+		for _, t := range append(append([]string{}, set.Defaults...), set.Match(dir, a.name)...) {
+			if !contains(e.Tags, t) {
+				e.Tags = append(e.Tags, t)
+			}
+		}
+		cats[a.src] = append(cats[a.src], e)
+		dirty[a.src] = true
+		msg := fmt.Sprintf("watch: new %s", filepath.Join(dir, a.name))
+		if len(e.Tags) > 0 {
+			msg += " (" + strings.Join(e.Tags, ", ") + ")"
+		}
+		w.Notify(msg)
+	}
+
+	changed := 0
+	for s, src := range w.Sources {
+		if !dirty[s] {
+			continue
+		}
+		// if the tombstones fail the vanished entries stay listed and the
+		// next sync tries again
+		keep := append(append([]catalog.CatEntry(nil), cats[s]...), gone[s]...)
+		if err := catalog.SaveRemoval(src, keep, cats[s], catalog.Vanished(gone[s])); err != nil {
+			return changed, err
+		}
+		changed++
+	}
+	return changed, nil
+}
+
+// tooManyRemovals reports whether a sync would remove more than the
+// allowed share of the entries of a catalog, and warns once per folder.
+func (w *Watcher) tooManyRemovals(cats [][]catalog.CatEntry, vanished []fileRef, moved map[fileRef]bool, limits []int) bool {
+	removed := make([]int, len(cats))
+	for _, v := range vanished {
+		if !moved[v] {
+			removed[v.src]++
+		}
+	}
+	over := false
+	for s, n := range removed {
+		dir := w.Sources[s].Dir
+		if n == 0 || n*100 <= limits[s]*len(cats[s]) {
+			delete(w.held, dir)
+			continue
+		}
+		over = true
+		if !w.held[dir] {
+			w.held[dir] = true
+			w.Notify(fmt.Sprintf("watch: warning: %d of %d entries of %s are gone; not syncing until the folder is complete or 'i' is run", n, len(cats[s]), dir))
+		}
+	}
+	return over
+}
+
+// renamedFrom finds the vanished file a new file was renamed or moved from.
+func (w *Watcher) renamedFrom(a fileRef, vanished []fileRef, used map[fileRef]bool) (fileRef, bool) {
+	if !w.Renames {
+		return fileRef{}, false
+	}
+	for _, v := range vanished {
+		if used[v] || v.fi == nil {
+			continue
+		}
+		if os.SameFile(v.fi, a.fi) || (v.fi.Size() == a.fi.Size() && v.fi.ModTime().Equal(a.fi.ModTime())) {
+			return v, true
+		}
+	}
+	return fileRef{}, false
+}
+
+// listFiles returns the visible regular files of dir that are not matched
+// by its [catalog] ignore patterns.
+func listFiles(dir string, cfg *config.Config) (map[string]os.FileInfo, error) {
+	list, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	files := map[string]os.FileInfo{}
+	for _, f := range list {
+		name := f.Name()
+		if name == "" || name[0] == '.' || f.IsDir() || cfg.Ignored(name) {
+			continue
+		}
+		if fi, err := f.Info(); err == nil {
+			files[name] = fi
+		}
+	}
+	return files, nil
+}
+
+// take removes the entry called name from entries and returns it.
+func take(entries *[]catalog.CatEntry, name string) catalog.CatEntry {
+	for i, e := range *entries {
+		if e.Name == name {
+			*entries = append((*entries)[:i], (*entries)[i+1:]...)
+			return e
+		}
+	}
+	return catalog.CatEntry{Name: name, Type: "file"}
+}
+
+func contains(tags []string, tag string) bool {
+	for _, t := range tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// Start syncs once and then keeps syncing in the background until Stop.
+func (w *Watcher) Start(interval time.Duration) error {
+	var dirs []string
+	for _, src := range w.Sources {
+		dirs = append(dirs, src.Dir)
+	}
+	if _, err := w.Sync(); err != nil {
+		return err
+	}
+	w.stop = make(chan struct{})
+	w.done = make(chan struct{})
+	events, closeEvents, err := notifier(dirs, w.stop)
+	w.mode = "inotify"
+	if err != nil {
+		w.mode = fmt.Sprintf("polling every %v", interval)
+	}
+	go func() {
+		defer close(w.done)
+		if closeEvents != nil {
+			defer closeEvents()
+		}
+		var tick <-chan time.Time
+		if events == nil {
+			t := time.NewTicker(interval)
+			defer t.Stop()
+			tick = t.C
+		}
+		for {
+			select {
+			case <-w.stop:
+				return
+			case <-tick:
+			case <-events:
+				time.Sleep(settle)
+				for len(events) > 0 {
+					<-events
+				}
+			}
+			if _, err := w.Sync(); err != nil {
+				w.Notify(fmt.Sprintf("watch: %v", err))
+			}
+		}
+	}()
+	return nil
+}
+
+// Stop ends background syncing.
+func (w *Watcher) Stop() {
+	close(w.stop)
+	<-w.done
+}
+
+var (
+	mu      sync.Mutex
+	running *Watcher
+)
+
+// CmdWatch starts or stops watching the current folder and its linked
+// folders: watch [on|off|status].
+func CmdWatch(out io.Writer, args []string) error {
+	mu.Lock()
+	defer mu.Unlock()
+	arg := "on"
+	if len(args) > 0 {
+		arg = args[0]
+	}
+	switch arg {
+	case "on":
+		if running != nil {
+			fmt.Fprintf(out, "already watching %d folders (%s)\n", len(running.Sources), running.mode)
+			return nil
+		}
+		cwd, _ := os.Getwd()
+		sources, err := catalog.ResolveSources(cwd)
+		if err != nil {
+			return fmt.Errorf("watch: %w", err)
+		}
+		cfg, err := config.Load()
+		if err != nil {
+			fmt.Fprintln(out, "config error:", err)
+		}
+		w := New(sources, cfg.Watch.Renames)
+		if err := w.Start(time.Duration(cfg.Watch.Interval) * time.Second); err != nil {
+			return fmt.Errorf("watch: %w", err)
+		}
+		running = w
+		fmt.Fprintf(out, "watching %d folders (%s)\n", len(sources), w.mode)
+	case "off":
+		if running == nil {
+			fmt.Fprintln(out, "not watching")
+			return nil
+		}
+		running.Stop()
+		running = nil
+		fmt.Fprintln(out, "stopped watching")
+	case "status":
+		if running == nil {
+			fmt.Fprintln(out, "not watching")
+			return nil
+		}
+		fmt.Fprintf(out, "watching (%s):\n", running.mode)
+		for _, src := range running.Sources {
+			fmt.Fprintln(out, "  "+src.Dir)
+		}
+	default:
+		return fmt.Errorf("usage: watch [on|off|status]")
+	}
+	return nil
+}
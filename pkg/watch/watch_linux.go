@@ -0,0 +1,56 @@
+package watch
+
+import (
+	"golang.org/x/sys/unix"
+)
+
+const watchMask = unix.IN_CREATE | unix.IN_DELETE | unix.IN_MOVED_FROM | unix.IN_MOVED_TO | unix.IN_CLOSE_WRITE
+
+// notifier reports changes in dirs through inotify. The returned channel
+// receives a value when something changed; the function closes the
+// inotify descriptor after the reader has stopped.
+func notifier(dirs []string, stop <-chan struct{}) (<-chan struct{}, func(), error) {
+	fd, err := unix.InotifyInit1(unix.IN_CLOEXEC | unix.IN_NONBLOCK)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, dir := range dirs {
+		if _, err := unix.InotifyAddWatch(fd, dir, watchMask); err != nil {
+			unix.Close(fd)
+			return nil, nil, err
+		}
+	}
+	events := make(chan struct{}, 1)
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		buf := make([]byte, 16*1024)
+		fds := []unix.PollFd{{Fd: int32(fd), Events: unix.POLLIN}}
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			// wake up regularly to notice stop
+			n, err := unix.Poll(fds, 500)
+			if err != nil && err != unix.EINTR {
+				return
+			}
+			if n <= 0 {
+				continue
+			}
+			if _, err := unix.Read(fd, buf); err != nil && err != unix.EAGAIN {
+				return
+			}
+			select {
+			case events <- struct{}{}:
+			default:
+			}
+		}
+	}()
+	return events, func() {
+		<-finished
+		unix.Close(fd)
+	}, nil
+}
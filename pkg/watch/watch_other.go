@@ -0,0 +1,10 @@
+//go:build !linux
+
+package watch
+
+import "errors"
+
+// notifier is only implemented with inotify; other systems poll.
+func notifier(dirs []string, stop <-chan struct{}) (<-chan struct{}, func(), error) {
+	return nil, nil, errors.New("no file change notification on this system")
+}
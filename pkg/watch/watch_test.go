@@ -0,0 +1,142 @@
+package watch
+
+import (
+    "fmt"
+    "io"
+    "os"
+    "path/filepath"
+    "strings"
+    "testing"
+    "time"
+
+    "github.com/tenzokai/filemac/pkg/catalog"
+)
+
+// newTestWatcher sets up two catalog folders; confirm is the [init]
+// confirm_percent of the test configuration.
+func newTestWatcher(t *testing.T, confirm int) (*Watcher, string, string, *[]string) {
+    home := t.TempDir()
+    t.Setenv("XDG_CONFIG_HOME", home)
+    os.MkdirAll(filepath.Join(home, "filemac"), 0755)
+    os.WriteFile(filepath.Join(home, "filemac", "config.toml"), []byte(fmt.Sprintf("[init]\nconfirm_percent = %d\n", confirm)), 0644)
+    a, b := t.TempDir(), t.TempDir()
+    catalog.SaveCatalogAt(filepath.Join(a, ".cat"), []catalog.CatEntry{
+        {Name: "old.pdf", Type: "file", Tags: []string{"steuer"}},
+        {Name: "gone.pdf", Type: "file"},
+        {Name: "https://example.org", Type: "url"},
+    })
+    catalog.SaveCatalogAt(filepath.Join(b, ".cat"), nil)
+    os.WriteFile(filepath.Join(a, "old.pdf"), []byte("1234"), 0644)
+    os.WriteFile(filepath.Join(b, ".catrules"), []byte("*allianz* -> versicherung\n"), 0644)
+    w := New([]catalog.Source{{Dir: a, File: filepath.Join(a, ".cat")}, {Dir: b, File: filepath.Join(b, ".cat")}}, true)
+    var msgs []string
+    w.Notify = func(m string) { msgs = append(msgs, m) }
+    return w, a, b, &msgs
+}
+
+func names(t *testing.T, dir string) string {
+    entries, err := catalog.LoadCatalogAt(filepath.Join(dir, ".cat"))
+    if err != nil {
+        t.Fatal(err)
+    }
+    var out []string
+    for _, e := range entries {
+        out = append(out, e.Name+"="+strings.Join(e.Tags, ","))
+    }
+    return strings.Join(out, " ")
+}
+
+func TestSyncMovesAndRules(t *testing.T) {
+    w, a, b, msgs := newTestWatcher(t, 50)
+    if _, err := w.Sync(); err != nil {
+        t.Fatal(err)
+    }
+    if got := names(t, a); got != "old.pdf=steuer https://example.org=" {
+        t.Fatalf("after first sync: %s", got)
+    }
+    // move a tagged file to the other folder and add a new one
+    os.Rename(filepath.Join(a, "old.pdf"), filepath.Join(b, "renamed.pdf"))
+    os.WriteFile(filepath.Join(b, "Allianz.pdf"), []byte("x"), 0644)
+    if _, err := w.Sync(); err != nil {
+        t.Fatal(err)
+    }
+    if got := names(t, a); got != "https://example.org=" {
+        t.Errorf("folder a: %s", got)
+    }
+    got := names(t, b)
+    if !strings.Contains(got, "renamed.pdf=steuer") || !strings.Contains(got, "Allianz.pdf=versicherung") {
+        t.Errorf("folder b: %s", got)
+    }
+    if len(*msgs) != 3 {
+        t.Errorf("notifications: %q", *msgs)
+    }
+}
+
+func TestSyncHoldsLargeRemovals(t *testing.T) {
+    w, a, _, msgs := newTestWatcher(t, 20)
+    os.Remove(filepath.Join(a, "old.pdf"))
+    for i := 0; i < 2; i++ {
+        if n, err := w.Sync(); err != nil || n != 0 {
+            t.Fatalf("Sync = %d, %v", n, err)
+        }
+    }
+    if got := names(t, a); got != "old.pdf=steuer gone.pdf= https://example.org=" {
+        t.Errorf("catalog changed: %s", got)
+    }
+    if len(*msgs) != 1 || !strings.Contains((*msgs)[0], "2 of 3 entries") {
+        t.Errorf("notifications: %q", *msgs)
+    }
+}
+
+func TestStartNotices(t *testing.T) {
+    w, _, b, _ := newTestWatcher(t, 50)
+    if err := w.Start(50 * time.Millisecond); err != nil {
+        t.Fatal(err)
+    }
+    defer w.Stop()
+    os.WriteFile(filepath.Join(b, "new.txt"), []byte("x"), 0644)
+    deadline := time.Now().Add(5 * time.Second)
+    for !strings.Contains(names(t, b), "new.txt") {
+        if time.Now().After(deadline) {
+            t.Fatal("new file was not added")
+        }
+        time.Sleep(20 * time.Millisecond)
+    }
+}
+
+func TestRenameWhileSyncing(t *testing.T) {
+    w, a, _, _ := newTestWatcher(t, 50)
+    if _, err := w.Sync(); err != nil {
+        t.Fatal(err)
+    }
+    t.Chdir(a)
+    t.Setenv("VISUAL", "")
+    t.Setenv("EDITOR", "sed -i s/old.pdf/new.pdf/")
+    stop, done := make(chan bool), make(chan bool)
+    go func() {
+        for {
+            select {
+            case <-stop:
+                done <- true
+                return
+            default:
+                w.Sync()
+            }
+        }
+    }()
+    err := catalog.CmdRenameEditor(io.Discard)
+    stop <- true
+    <-done
+    if err != nil {
+        t.Fatal(err)
+    }
+    if got := names(t, a); got != "https://example.org= new.pdf=steuer" && got != "new.pdf=steuer https://example.org=" {
+        t.Errorf("after ren: %s", got)
+    }
+    ts, _ := catalog.LoadTombstones(a)
+    for _, tomb := range ts {
+        if tomb.Entry.Name != "gone.pdf" {
+            t.Errorf("the watcher saw %s vanish during ren", tomb.Entry.Name)
+        }
+    }
+}
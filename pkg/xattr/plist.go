@@ -0,0 +1,225 @@
+package xattr
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strings"
+	"unicode/utf16"
+)
+
+// Finder stores tags as a binary property list ("bplist00") holding an
+// array of strings. Only that shape is supported here.
+
+// encodePlistStrings returns a binary plist containing one array of strings.
+func encodePlistStrings(list []string) []byte {
+	numObjects := len(list) + 1
+	refSize := 1
+	if numObjects > 0xff {
+		refSize = 2
+	}
+	buf := []byte("bplist00")
+	offsets := make([]int, 0, numObjects)
+
+	offsets = append(offsets, len(buf))
+	buf = appendMarker(buf, 0xa0, len(list))
+	for i := range list {
+		buf = appendUint(buf, uint64(i+1), refSize)
+	}
+	for _, s := range list {
+		offsets = append(offsets, len(buf))
+		if isASCII(s) {
+			buf = appendMarker(buf, 0x50, len(s))
+			buf = append(buf, s...)
+			continue
+		}
+		units := utf16.Encode([]rune(s))
+		buf = appendMarker(buf, 0x60, len(units))
+		for _, u := range units {
+			buf = binary.BigEndian.AppendUint16(buf, u)
+		}
+	}
+
+	tableOffset := len(buf)
+	offsetSize := uintSize(uint64(tableOffset))
+	for _, off := range offsets {
+		buf = appendUint(buf, uint64(off), offsetSize)
+	}
+	trailer := make([]byte, 32)
+	trailer[6] = byte(offsetSize)
+	trailer[7] = byte(refSize)
+	binary.BigEndian.PutUint64(trailer[8:], uint64(numObjects))
+	binary.BigEndian.PutUint64(trailer[16:], 0)
+	binary.BigEndian.PutUint64(trailer[24:], uint64(tableOffset))
+	return append(buf, trailer...)
+}
+
+// decodePlistStrings parses a binary plist whose top object is an array of
+// strings.
+func decodePlistStrings(data []byte) ([]string, error) {
+	if len(data) < 40 || string(data[:8]) != "bplist00" {
+		return nil, fmt.Errorf("not a binary plist")
+	}
+	trailer := data[len(data)-32:]
+	offsetSize := int(trailer[6])
+	refSize := int(trailer[7])
+	numObjects := binary.BigEndian.Uint64(trailer[8:])
+	top := binary.BigEndian.Uint64(trailer[16:])
+	tableOffset := binary.BigEndian.Uint64(trailer[24:])
+	tableEnd := uint64(len(data) - 32)
+	if offsetSize < 1 || offsetSize > 8 || refSize < 1 || refSize > 8 ||
+		top >= numObjects || tableOffset > tableEnd || numObjects > (tableEnd-tableOffset)/uint64(offsetSize) {
+		return nil, fmt.Errorf("corrupt plist trailer")
+	}
+	offset := func(ref uint64) (int, error) {
+		if ref >= numObjects {
+			return 0, fmt.Errorf("object reference out of range")
+		}
+		pos := int(tableOffset) + int(ref)*offsetSize
+		off := readUint(data[pos : pos+offsetSize])
+		if off >= uint64(len(data)) {
+			return 0, fmt.Errorf("object offset out of range")
+		}
+		return int(off), nil
+	}
+
+	pos, err := offset(top)
+	if err != nil {
+		return nil, err
+	}
+	if data[pos]&0xf0 != 0xa0 {
+		return nil, fmt.Errorf("top object is not an array")
+	}
+	count, pos, err := readLength(data, pos)
+	if err != nil {
+		return nil, err
+	}
+	if count > (len(data)-pos)/refSize {
+		return nil, fmt.Errorf("array out of range")
+	}
+	var list []string
+	for i := 0; i < count; i++ {
+		ref := readUint(data[pos+i*refSize : pos+(i+1)*refSize])
+		objPos, err := offset(ref)
+		if err != nil {
+			return nil, err
+		}
+		s, err := readString(data, objPos)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, s)
+	}
+	return list, nil
+}
+
+// stripFinderColors removes the "\n<color>" suffix Finder appends to tags.
+func stripFinderColors(tags []string) []string {
+	out := make([]string, 0, len(tags))
+	for _, t := range tags {
+		if i := strings.IndexByte(t, '\n'); i != -1 {
+			t = t[:i]
+		}
+		if t != "" {
+			out = append(out, t)
+		}
+	}
+	return out
+}
+
+func readString(data []byte, pos int) (string, error) {
+	kind := data[pos] & 0xf0
+	n, pos, err := readLength(data, pos)
+	if err != nil {
+		return "", err
+	}
+	switch kind {
+	case 0x50:
+		if n > len(data)-pos {
+			return "", fmt.Errorf("string out of range")
+		}
+		return string(data[pos : pos+n]), nil
+	case 0x60:
+		if n > (len(data)-pos)/2 {
+			return "", fmt.Errorf("string out of range")
+		}
+		units := make([]uint16, n)
+		for i := range units {
+			units[i] = binary.BigEndian.Uint16(data[pos+2*i:])
+		}
+		return string(utf16.Decode(units)), nil
+	}
+	return "", fmt.Errorf("unsupported plist object 0x%02x", kind)
+}
+
+// readLength decodes the length nibble of the marker at pos, following the
+// extended integer encoding for lengths >= 15. It returns the length and
+// the position of the object payload. Lengths beyond the data are rejected,
+// so callers can add them to a position without overflow.
+func readLength(data []byte, pos int) (int, int, error) {
+	n := int(data[pos] & 0x0f)
+	pos++
+	if n != 0x0f {
+		return n, pos, nil
+	}
+	if pos >= len(data) || data[pos]&0xf0 != 0x10 {
+		return 0, 0, fmt.Errorf("bad length marker")
+	}
+	size := 1 << (data[pos] & 0x0f)
+	pos++
+	if size > 8 || pos+size > len(data) {
+		return 0, 0, fmt.Errorf("bad length marker")
+	}
+	v := readUint(data[pos : pos+size])
+	if v > uint64(len(data)) {
+		return 0, 0, fmt.Errorf("length %d out of range", v)
+	}
+	return int(v), pos + size, nil
+}
+
+func appendMarker(buf []byte, kind byte, n int) []byte {
+	if n < 0x0f {
+		return append(buf, kind|byte(n))
+	}
+	buf = append(buf, kind|0x0f)
+	size := uintSize(uint64(n))
+	exp := map[int]byte{1: 0, 2: 1, 4: 2, 8: 3}[size]
+	buf = append(buf, 0x10|exp)
+	return appendUint(buf, uint64(n), size)
+}
+
+func appendUint(buf []byte, v uint64, size int) []byte {
+	for i := size - 1; i >= 0; i-- {
+		buf = append(buf, byte(v>>(8*uint(i))))
+	}
+	return buf
+}
+
+func readUint(b []byte) uint64 {
+	var v uint64
+	for _, c := range b {
+		v = v<<8 | uint64(c)
+	}
+	return v
+}
+
+// uintSize returns the smallest of 1, 2, 4 or 8 bytes that holds v.
+func uintSize(v uint64) int {
+	switch {
+	case v <= 0xff:
+		return 1
+	case v <= 0xffff:
+		return 2
+	case v <= 0xffffffff:
+		return 4
+	}
+	return 8
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= 0x80 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,283 @@
+package xattr
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/tenzokai/filemac/pkg/catalog"
+)
+
+// ErrUnsupported is returned on platforms without extended attribute support.
+var ErrUnsupported = errors.New("extended attributes are not supported on this platform")
+
+// ErrBadTag is returned by WriteTags for tags the attribute cannot hold,
+// e.g. tags with a comma in the comma separated user.xdg.tags.
+var ErrBadTag = errors.New("tag cannot be stored in the attribute")
+
+// Mode selects the direction of a sync.
+type Mode string
+
+const (
+	Push  Mode = "push"  // catalog tags overwrite attributes
+	Pull  Mode = "pull"  // attribute tags overwrite catalog tags
+	Merge Mode = "merge" // both sides are merged, see merge
+)
+
+// Stats summarizes a sync run.
+type Stats struct {
+	Written  int      // files whose attribute was written or removed
+	Imported int      // entries whose catalog tags changed
+	Missing  int      // file entries without a file on disk
+	Skipped  []string // folders and files left alone, with the reason
+}
+
+// ReadTags returns the tags stored in the extended attribute of path.
+// A file without the attribute has no tags.
+func ReadTags(path string) ([]string, error) {
+	data, err := getAttr(path, attrName)
+	if err != nil || data == nil {
+		return nil, err
+	}
+	return decodeTags(data)
+}
+
+// WriteTags stores tags in the extended attribute of path. An empty list
+// removes the attribute.
+func WriteTags(path string, tags []string) error {
+	if len(tags) == 0 {
+		return removeAttr(path, attrName)
+	}
+	data, err := encodeTags(tags)
+	if err != nil {
+		return err
+	}
+	return setAttr(path, attrName, data)
+}
+
+// CmdXattr runs "xattr sync [push|pull|merge]" (merge is the default).
+func CmdXattr(w io.Writer, args []string) error {
+	if len(args) == 0 || args[0] != "sync" {
+		return fmt.Errorf("usage: xattr sync [push|pull|merge]")
+	}
+	mode := Merge
+	if len(args) > 1 {
+		mode = Mode(args[1])
+	}
+	if mode != Push && mode != Pull && mode != Merge {
+		return fmt.Errorf("unknown mode %q, use push, pull or merge", args[1])
+	}
+	cwd, _ := os.Getwd()
+	st, err := Sync(cwd, mode)
+	if err != nil {
+		return fmt.Errorf("xattr: %w", err)
+	}
+	for _, s := range st.Skipped {
+		fmt.Fprintln(w, "skipped", s)
+	}
+	fmt.Fprintf(w, "xattr %s (%s): %d files written, %d entries updated, %d files missing\n",
+		mode, attrName, st.Written, st.Imported, st.Missing)
+	return nil
+}
+
+// StateFilename is kept next to each .cat and records the tags every file
+// had after the last sync, so merge can tell a removed tag from a new one.
+var StateFilename = ".catxattr"
+
+// Sync synchronizes the tags of every file entry in the catalogs visible
+// from dir with the files' extended attributes. URL entries are skipped,
+// and so are folders whose file system has no extended attributes and
+// files whose tags the attribute cannot hold; Stats.Skipped says why.
+func Sync(dir string, mode Mode) (Stats, error) {
+	catalog.Lock()
+	defer catalog.Unlock()
+	var st Stats
+	sources, err := catalog.ResolveSources(dir)
+	if err != nil {
+		return st, err
+	}
+	for _, src := range sources {
+		// probe before anything is written
+		if _, err := getAttr(src.File, attrName); errors.Is(err, ErrUnsupported) {
+			return st, err
+		} else if notSupported(err) {
+			st.Skipped = append(st.Skipped, fmt.Sprintf("%s: %v", src.Dir, err))
+			continue
+		}
+		entries, err := catalog.LoadCatalogAt(src.File)
+		if err != nil {
+			return st, err
+		}
+		stateFile := filepath.Join(src.Dir, StateFilename)
+		synced, err := loadState(stateFile)
+		if err != nil {
+			return st, err
+		}
+		dirty := false
+		for i := range entries {
+			e := &entries[i]
+			if e.Type != "file" {
+				continue
+			}
+			path := catalog.EntryPath(src.Dir, *e)
+			if _, err := os.Stat(path); err != nil {
+				st.Missing++
+				continue
+			}
+			attrTags, err := ReadTags(path)
+			if notSupported(err) {
+				st.Skipped = append(st.Skipped, fmt.Sprintf("%s: %v", path, err))
+				continue
+			} else if err != nil {
+				return st, fmt.Errorf("%s: %v", path, err)
+			}
+			want := e.Tags
+			switch mode {
+			case Pull:
+				if attrTags == nil {
+					continue
+				}
+				want = attrTags
+			case Merge:
+				base, ok := synced[e.Name]
+				want = merge(base, ok, e.Tags, attrTags)
+			}
+			if mode != Pull && !equal(want, attrTags) {
+				err := WriteTags(path, want)
+				if errors.Is(err, ErrBadTag) || notSupported(err) {
+					st.Skipped = append(st.Skipped, fmt.Sprintf("%s: %v", path, err))
+					continue
+				} else if err != nil {
+					return st, fmt.Errorf("%s: %v", path, err)
+				}
+				st.Written++
+			}
+			if !equal(want, e.Tags) {
+				e.Tags = want
+				dirty = true
+				st.Imported++
+			}
+			synced[e.Name] = want
+		}
+		if dirty {
+			if err := catalog.SaveCatalogAt(src.File, entries); err != nil {
+				return st, err
+			}
+		}
+		listed := map[string]bool{}
+		for _, e := range entries {
+			listed[e.Name] = true
+		}
+		for name := range synced {
+			if !listed[name] {
+				delete(synced, name)
+			}
+		}
+		if err := saveState(stateFile, synced); err != nil {
+			return st, err
+		}
+	}
+	return st, nil
+}
+
+// merge returns the tags of both sides. With the tags of the last sync
+// (known) a tag that is gone on one side is removed, so removals made in
+// the catalog or in the file manager propagate; otherwise the union is used.
+func merge(base []string, known bool, cat, attr []string) []string {
+	all := union(cat, attr)
+	if !known {
+		return all
+	}
+	var out []string
+	for _, t := range all {
+		if contains(base, t) && (!contains(cat, t) || !contains(attr, t)) {
+			continue
+		}
+		out = append(out, t)
+	}
+	return out
+}
+
+// loadState reads the tags of the last sync, by entry name.
+func loadState(file string) (map[string][]string, error) {
+	state := map[string][]string{}
+	entries, err := catalog.LoadCatalogAt(file)
+	if os.IsNotExist(err) {
+		return state, nil
+	} else if err != nil {
+		return nil, err
+	}
+	for _, e := range entries {
+		state[e.Name] = e.Tags
+	}
+	return state, nil
+}
+
+// saveState writes the tags of the last sync in the .cat line format.
+func saveState(file string, state map[string][]string) error {
+	names := make([]string, 0, len(state))
+	for name := range state {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	entries := make([]catalog.CatEntry, len(names))
+	for i, name := range names {
+		entries[i] = catalog.CatEntry{Name: name, Type: "file", Tags: state[name]}
+	}
+	return catalog.SaveCatalogAt(file, entries)
+}
+
+// union returns a followed by the tags of b not already in a.
+func union(a, b []string) []string {
+	out := append([]string(nil), a...)
+	for _, t := range b {
+		found := false
+		for _, have := range out {
+			if have == t {
+				found = true
+				break
+			}
+		}
+		if !found {
+			out = append(out, t)
+		}
+	}
+	return out
+}
+
+func contains(tags []string, tag string) bool {
+	for _, t := range tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+func equal(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// splitList parses a comma separated attribute value.
+func splitList(s string) []string {
+	var tags []string
+	for _, t := range strings.Split(s, ",") {
+		t = strings.TrimSpace(t)
+		if t != "" {
+			tags = append(tags, t)
+		}
+	}
+	return tags
+}
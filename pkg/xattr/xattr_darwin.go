@@ -0,0 +1,22 @@
+package xattr
+
+import (
+	"golang.org/x/sys/unix"
+)
+
+// attrName holds Finder tags as a binary property list of strings.
+const attrName = "com.apple.metadata:_kMDItemUserTags"
+
+var errNoAttr error = unix.ENOATTR
+
+func encodeTags(tags []string) ([]byte, error) {
+	return encodePlistStrings(tags), nil
+}
+
+func decodeTags(data []byte) ([]string, error) {
+	tags, err := decodePlistStrings(data)
+	if err != nil {
+		return nil, err
+	}
+	return stripFinderColors(tags), nil
+}
@@ -0,0 +1,28 @@
+package xattr
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+// attrName follows the freedesktop.org convention for file tags.
+const attrName = "user.xdg.tags"
+
+var errNoAttr error = unix.ENODATA
+
+// encodeTags joins tags with commas; a tag with a comma would come back
+// as two tags, so it is refused.
+func encodeTags(tags []string) ([]byte, error) {
+	for _, t := range tags {
+		if strings.Contains(t, ",") {
+			return nil, fmt.Errorf("%w: '%s' contains ','", ErrBadTag, t)
+		}
+	}
+	return []byte(strings.Join(tags, ",")), nil
+}
+
+func decodeTags(data []byte) ([]string, error) {
+	return splitList(string(data)), nil
+}
@@ -0,0 +1,17 @@
+//go:build !linux && !darwin
+
+package xattr
+
+const attrName = "user.xdg.tags"
+
+func getAttr(path, attr string) ([]byte, error) { return nil, ErrUnsupported }
+
+func setAttr(path, attr string, data []byte) error { return ErrUnsupported }
+
+func removeAttr(path, attr string) error { return ErrUnsupported }
+
+func encodeTags(tags []string) ([]byte, error) { return nil, ErrUnsupported }
+
+func notSupported(err error) bool { return false }
+
+func decodeTags(data []byte) ([]string, error) { return nil, ErrUnsupported }
@@ -0,0 +1,121 @@
+package xattr
+
+import (
+    "os"
+    "path/filepath"
+    "runtime"
+    "strings"
+    "testing"
+
+    "github.com/tenzokai/filemac/pkg/catalog"
+)
+
+func TestPlistRoundtrip(t *testing.T) {
+    in := []string{"steuer", "Kündigung", "Rot\n6"}
+    out, err := decodePlistStrings(encodePlistStrings(in))
+    if err != nil {
+        t.Fatalf("decode: %v", err)
+    }
+    if len(out) != 3 || out[1] != "Kündigung" || out[2] != "Rot\n6" {
+        t.Errorf("roundtrip mismatch: %q", out)
+    }
+    if tags := stripFinderColors(out); tags[2] != "Rot" {
+        t.Errorf("color suffix not stripped: %q", tags)
+    }
+}
+
+func TestPlistCorrupt(t *testing.T) {
+    valid := encodePlistStrings([]string{"steuer", "Kündigung"})
+    // the first string object follows the header and the array marker with
+    // its two one-byte references
+    str := 8 + 3
+    // overwrite writes b over the data at pos without moving anything
+    overwrite := func(pos int, b ...byte) []byte {
+        data := append([]byte(nil), valid...)
+        copy(data[pos:], b)
+        return data
+    }
+    cases := map[string][]byte{
+        "huge length":       overwrite(str, 0x5f, 0x13, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xf0),
+        "length past end":   overwrite(str, 0x5f, 0x10, 0xfe),
+        "huge object count": overwrite(len(valid)-24, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff),
+        "truncated":         valid[:len(valid)-33],
+    }
+    for name, data := range cases {
+        if _, err := decodePlistStrings(data); err == nil {
+            t.Errorf("%s: corrupt plist accepted", name)
+        }
+    }
+}
+
+func TestSyncMerge(t *testing.T) {
+    dir := t.TempDir()
+    doc := filepath.Join(dir, "a.pdf")
+    os.WriteFile(doc, []byte("x"), 0644)
+    if err := WriteTags(doc, []string{"bank"}); err != nil {
+        t.Skipf("user xattrs not available here: %v", err)
+    }
+    catalog.SaveCatalogAt(filepath.Join(dir, ".cat"), []catalog.CatEntry{
+        {Name: "a.pdf", Type: "file", Tags: []string{"steuer"}},
+    })
+    st, err := Sync(dir, Merge)
+    if err != nil {
+        t.Fatalf("Sync: %v", err)
+    }
+    if st.Written != 1 || st.Imported != 1 {
+        t.Errorf("unexpected stats: %+v", st)
+    }
+    attr, _ := ReadTags(doc)
+    entries, _ := catalog.LoadCatalogAt(filepath.Join(dir, ".cat"))
+    if len(attr) != 2 || len(entries[0].Tags) != 2 || entries[0].Tags[1] != "bank" {
+        t.Errorf("merge failed: attr=%v cat=%v", attr, entries[0].Tags)
+    }
+
+    // a tag removed in the catalog is removed from the file, and the other
+    // way round, instead of coming back
+    entries[0].Tags = []string{"steuer", "2024"}
+    catalog.SaveCatalogAt(filepath.Join(dir, ".cat"), entries)
+    if _, err := Sync(dir, Merge); err != nil {
+        t.Fatal(err)
+    }
+    if attr, _ := ReadTags(doc); strings.Join(attr, ",") != "steuer,2024" {
+        t.Errorf("removal not pushed: %v", attr)
+    }
+    WriteTags(doc, []string{"2024"})
+    Sync(dir, Merge)
+    entries, _ = catalog.LoadCatalogAt(filepath.Join(dir, ".cat"))
+    if strings.Join(entries[0].Tags, ",") != "2024" {
+        t.Errorf("removal not pulled: %v", entries[0].Tags)
+    }
+
+    if runtime.GOOS == "linux" {
+        // user.xdg.tags is a comma list: such a tag is not written
+        entries[0].Tags = []string{"2024", "a,b"}
+        catalog.SaveCatalogAt(filepath.Join(dir, ".cat"), entries)
+        st, err := Sync(dir, Merge)
+        if err != nil || len(st.Skipped) != 1 || !strings.Contains(st.Skipped[0], "a,b") {
+            t.Errorf("comma tag: %+v, %v", st, err)
+        }
+        if attr, _ := ReadTags(doc); strings.Join(attr, ",") != "2024" {
+            t.Errorf("comma tag written: %q", attr)
+        }
+    }
+}
+
+func TestMerge(t *testing.T) {
+    cases := []struct {
+        base      []string
+        known     bool
+        cat, attr []string
+        want      string
+    }{
+        {nil, false, []string{"a"}, []string{"b"}, "a,b"},
+        {[]string{"a", "b"}, true, []string{"a"}, []string{"a", "b"}, "a"},
+        {[]string{"a", "b"}, true, []string{"a", "b", "c"}, []string{"b", "d"}, "b,c,d"},
+    }
+    for i, c := range cases {
+        if got := strings.Join(merge(c.base, c.known, c.cat, c.attr), ","); got != c.want {
+            t.Errorf("case %d: merge = %q, want %q", i, got, c.want)
+        }
+    }
+}
@@ -0,0 +1,49 @@
+//go:build linux || darwin
+
+package xattr
+
+import (
+	"errors"
+
+	"golang.org/x/sys/unix"
+)
+
+// getAttr returns the value of attr on path, or nil if it is not set.
+func getAttr(path, attr string) ([]byte, error) {
+	for {
+		size, err := unix.Getxattr(path, attr, nil)
+		if err == errNoAttr {
+			return nil, nil
+		} else if err != nil {
+			return nil, err
+		}
+		buf := make([]byte, size)
+		n, err := unix.Getxattr(path, attr, buf)
+		if err == unix.ERANGE {
+			continue // value grew in between
+		} else if err == errNoAttr {
+			return nil, nil
+		} else if err != nil {
+			return nil, err
+		}
+		return buf[:n], nil
+	}
+}
+
+func setAttr(path, attr string, data []byte) error {
+	return unix.Setxattr(path, attr, data, 0)
+}
+
+// notSupported reports whether err means the file system of a file has no
+// extended attributes.
+func notSupported(err error) bool {
+	return errors.Is(err, unix.ENOTSUP) || errors.Is(err, unix.EOPNOTSUPP)
+}
+
+func removeAttr(path, attr string) error {
+	err := unix.Removexattr(path, attr)
+	if err == errNoAttr {
+		return nil
+	}
+	return err
+}